@@ -0,0 +1,100 @@
+package authCommon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// CallbackQuery :
+// - Configures CallbackSuccess
+// - Query runs against Schema once the user is logged in, like `{ me { name } }`
+type CallbackQuery struct {
+	Schema        *graphql.Schema
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	// Exec runs the query, Schema.Exec when nil. Use authDirective's Enforcer.Exec to check the directives
+	Exec func(ctx context.Context, schema *graphql.Schema, query string, operationName string, variables map[string]interface{}) *graphql.Response
+	// Template, when set, renders an HTML page with the CallbackPage; otherwise the response is JSON
+	Template *template.Template
+}
+
+// CallbackPage is what the Template of a CallbackQuery renders
+type CallbackPage struct {
+	User     *User
+	Response *graphql.Response
+	// JSON is the GraphQL response, safe to embed in a <script>
+	JSON template.JS
+}
+
+// DefaultCallbackTemplate :
+// - Greets the user
+// - Exposes the GraphQL response to the scripts of the page as window.graphqlAuthResponse
+var DefaultCallbackTemplate = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authenticated</title></head>
+<body>
+<p><strong>Hello {{.User.GivenName}} {{.User.FamilyName}}</strong></p>
+<p>You are authenticated!</p>
+<script>window.graphqlAuthResponse = {{.JSON}};</script>
+</body>
+</html>
+`))
+
+func (q *CallbackQuery) exec(ctx context.Context) *graphql.Response {
+	if q.Exec != nil {
+		return q.Exec(ctx, q.Schema, q.Query, q.OperationName, q.Variables)
+	}
+	return q.Schema.Exec(ctx, q.Query, q.OperationName, q.Variables)
+}
+
+// CallbackSuccess :
+// - Comes last on the callback route, after the provider handler (and the session handler)
+// - Runs the query with the new user in ctx, so resolvers see it like on /graphql
+// - Writes the GraphQL response as JSON, or as an HTML page when a Template is set
+// - The failure handler is called when ctx has no user
+func CallbackSuccess(q *CallbackQuery, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		response := q.exec(ctx)
+		body, err := json.Marshal(response)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if q.Template == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+			return
+		}
+
+		// Render before writing, so a template error still reaches failure
+		var page bytes.Buffer
+		err = q.Template.Execute(&page, &CallbackPage{User: user, Response: response, JSON: template.JS(body)})
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	}
+	return http.HandlerFunc(fn)
+}
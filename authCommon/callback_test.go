@@ -0,0 +1,51 @@
+package authCommon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type callbackResolver struct{}
+
+func (r *callbackResolver) Me(ctx context.Context) *string {
+	user, err := UserFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	return &user.Name
+}
+
+var callbackSchema = graphql.MustParseSchema(`
+	schema { query: Query }
+	type Query { me: String }
+`, &callbackResolver{})
+
+func Test_CallbackSuccess(t *testing.T) {
+	user := &User{Provider: "google", Subject: "42", Name: "Bob </script>", GivenName: "Bob"}
+	ctx := UserToContext(context.Background(), user)
+	query := &CallbackQuery{Schema: callbackSchema, Query: "{ me }"}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	CallbackSuccess(query, nil).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"data":{"me":"Bob </script>"}}`, w.Body.String())
+
+	query.Template = DefaultCallbackTemplate
+	w = httptest.NewRecorder()
+	CallbackSuccess(query, nil).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `window.graphqlAuthResponse = {"data":{"me":"Bob \u003c/script\u003e"}};`)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), "</script>"))
+
+	// No user, no query
+	w = httptest.NewRecorder()
+	CallbackSuccess(query, nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -2,20 +2,35 @@ package authCommon
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/astenmies/graphql-go-auth/authUtils"
 	"golang.org/x/oauth2"
 )
 
+// Error messages
+var (
+	ErrMissingState       = authUtils.NewError("oauth2: Context missing state value", authUtils.CodeInvalidState)
+	ErrMissingVerifier    = authUtils.NewError("oauth2: Context missing code verifier", authUtils.CodeInvalidState)
+	ErrMissingUser        = authUtils.NewError("oauth2: Context missing User", authUtils.CodeUnauthenticated)
+	ErrMissingToken       = authUtils.NewError("oauth2: Context missing Token", authUtils.CodeUnauthenticated)
+	ErrMissingCodeOrState = authUtils.NewError("oauth2: Request missing code or state", authUtils.CodeBadRequest)
+)
+
 type key int
 
 // Anti-collision keys for context
 const (
-	TokenKey   key = iota
-	StateKey   key = iota
-	AuthURLKey key = iota
+	TokenKey        key = iota
+	StateKey        key = iota
+	AuthURLKey      key = iota
+	VerifierKey     key = iota
+	CookieKey       key = iota
+	UserKey         key = iota
+	ProviderKey     key = iota
+	ReturnToKey     key = iota
+	FlowProviderKey key = iota
 )
 
 // StateToContext adds the state to ctx
@@ -28,6 +43,36 @@ func AuthURLToContext(ctx context.Context, authURL string) context.Context {
 	return context.WithValue(ctx, AuthURLKey, authURL)
 }
 
+// VerifierToContext adds the PKCE code verifier to ctx
+func VerifierToContext(ctx context.Context, verifier string) context.Context {
+	return context.WithValue(ctx, VerifierKey, verifier)
+}
+
+// CookieConfigToContext adds the state cookie config to ctx
+func CookieConfigToContext(ctx context.Context, config *authUtils.Config) context.Context {
+	return context.WithValue(ctx, CookieKey, config)
+}
+
+// UserToContext adds user to ctx
+func UserToContext(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, UserKey, user)
+}
+
+// ProviderNameToContext adds the provider name to ctx
+func ProviderNameToContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ProviderKey, name)
+}
+
+// FlowProviderToContext adds the provider name the flow of the callback started with to ctx, "" if none
+func FlowProviderToContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, FlowProviderKey, name)
+}
+
+// ReturnToToContext adds the returnTo URL of the flow to ctx
+func ReturnToToContext(ctx context.Context, returnTo string) context.Context {
+	return context.WithValue(ctx, ReturnToKey, returnTo)
+}
+
 // TokenToContext adds token to ctx
 func TokenToContext(ctx context.Context, token *oauth2.Token) context.Context {
 	return context.WithValue(ctx, TokenKey, token)
@@ -37,11 +82,74 @@ func TokenToContext(ctx context.Context, token *oauth2.Token) context.Context {
 func StateFromContext(ctx context.Context) (string, error) {
 	state, ok := ctx.Value(StateKey).(string)
 	if !ok {
-		return "", fmt.Errorf("oauth2: Context missing state value")
+		return "", ErrMissingState
 	}
 	return state, nil
 }
 
+// VerifierFromContext returns the PKCE code verifier from ctx
+func VerifierFromContext(ctx context.Context) (string, error) {
+	verifier, ok := ctx.Value(VerifierKey).(string)
+	if !ok || verifier == "" {
+		return "", ErrMissingVerifier
+	}
+	return verifier, nil
+}
+
+// CookieConfigFromContext returns the state cookie config from ctx
+func CookieConfigFromContext(ctx context.Context) (*authUtils.Config, error) {
+	config, ok := ctx.Value(CookieKey).(*authUtils.Config)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing cookie config")
+	}
+	return config, nil
+}
+
+// UserFromContext returns the user from ctx
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(UserKey).(*User)
+	if !ok {
+		return nil, ErrMissingUser
+	}
+	return user, nil
+}
+
+// ProviderNameFromContext returns the provider name from ctx
+func ProviderNameFromContext(ctx context.Context) (string, error) {
+	name, ok := ctx.Value(ProviderKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing provider name")
+	}
+	return name, nil
+}
+
+// FlowProviderFromContext returns the provider name the flow of the callback started with
+func FlowProviderFromContext(ctx context.Context) (string, error) {
+	name, ok := ctx.Value(FlowProviderKey).(string)
+	if !ok {
+		return "", ErrMissingState
+	}
+	return name, nil
+}
+
+// ReturnToFromContext returns the returnTo URL of the flow from ctx
+func ReturnToFromContext(ctx context.Context) (string, error) {
+	returnTo, ok := ctx.Value(ReturnToKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing returnTo")
+	}
+	return returnTo, nil
+}
+
+// NonceFromContext returns the OpenID Connect nonce of the flow in ctx
+func NonceFromContext(ctx context.Context) (string, error) {
+	verifier, err := VerifierFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return NonceForVerifier(verifier), nil
+}
+
 // StateAndCodeFromReq returns state and code from req
 func StateAndCodeFromReq(req *http.Request) (authCode, state string, err error) {
 	err = req.ParseForm()
@@ -52,7 +160,7 @@ func StateAndCodeFromReq(req *http.Request) (authCode, state string, err error)
 	state = req.Form.Get("state")
 
 	if authCode == "" || state == "" {
-		return "", "", errors.New("Oauth2: Request missing code or state")
+		return "", "", ErrMissingCodeOrState
 	}
 	return authCode, state, nil
 }
@@ -61,7 +169,7 @@ func StateAndCodeFromReq(req *http.Request) (authCode, state string, err error)
 func TokenFromContext(ctx context.Context) (*oauth2.Token, error) {
 	token, ok := ctx.Value(TokenKey).(*oauth2.Token)
 	if !ok {
-		return nil, fmt.Errorf("oauth2: Context missing Token")
+		return nil, ErrMissingToken
 	}
 	return token, nil
 }
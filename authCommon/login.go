@@ -2,16 +2,16 @@ package authCommon
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
 	authUtils "github.com/astenmies/graphql-go-auth/authUtils"
-	graphql "github.com/graph-gophers/graphql-go"
 	"golang.org/x/oauth2"
 )
 
@@ -22,111 +22,247 @@ func randomState() string {
 	return base64.RawURLEncoding.EncodeToString(b)
 }
 
-// Error messages
-var (
-	ErrInvalidState = errors.New("oauth2: Invalid OAuth2 state parameter")
-)
+// flow is what the state cookie of a pending login holds
+type flow struct {
+	State    string
+	Verifier string
+	// Provider is the name of the provider of a Registry the flow started with, if any
+	Provider string
+	ReturnTo string
+}
 
-type testr struct {
-	Query string
+// encodeFlow :
+// - Packs the state, its PKCE code verifier, the provider name and the returnTo URL into a single cookie value
+// - All are base64 url encoded, so "." can safely be used as a separator
+func encodeFlow(f flow) string {
+	return f.State + "." + f.Verifier + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(f.Provider)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(f.ReturnTo))
 }
 
-type Handler struct {
-	Schema *graphql.Schema
+// decodeFlow :
+// - Unpacks a cookie value written by encodeFlow
+// - Returns false if the value doesn't hold a state and a verifier, followed by the provider and returnTo
+func decodeFlow(value string) (flow, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 4 || parts[0] == "" || parts[1] == "" {
+		return flow{}, false
+	}
+	provider, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return flow{}, false
+	}
+	returnTo, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return flow{}, false
+	}
+	return flow{State: parts[0], Verifier: parts[1], Provider: string(provider), ReturnTo: string(returnTo)}, true
 }
 
-func contains(arr []string, str string) bool {
-	for _, a := range arr {
-		if a == str {
-			return true
-		}
+// NonceForVerifier :
+// - Derives the OpenID Connect nonce of a flow from its PKCE code verifier
+// - The nonce is sent on the auth URL and comes back in the id_token
+// - The callback derives it again from the verifier in its state cookie
+func NonceForVerifier(verifier string) string {
+	sum := sha256.Sum256([]byte("nonce:" + verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NonceOptions returns the auth URL parameter carrying the OpenID Connect nonce of the flow in ctx,
+// OpenID Connect providers return it from AuthCodeOptions
+func NonceOptions(ctx context.Context) []oauth2.AuthCodeOption {
+	nonce, err := NonceFromContext(ctx)
+	if err != nil {
+		return nil
 	}
-	return false
+	return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("nonce", nonce)}
 }
 
-func isTrigger(config *authUtils.Config, query string) bool {
-	// If our string contains "IntrospectionQuery"
-	// it means we're not analysing the right graphql query
-	if !strings.Contains(query, "IntrospectionQuery") {
-		// Replace all special characters
-		var replacer = strings.NewReplacer(" ", "", "\n", "", "(", "|", "{", "|") // each odd is the char to be replaced
-		var result = replacer.Replace(query)
+// Error messages
+var (
+	ErrInvalidState = authUtils.NewError("oauth2: Invalid OAuth2 state parameter", authUtils.CodeInvalidState)
+)
 
-		// Split the new string
-		var resultSlice = strings.Split(result, "|")
-		var isMutation = contains(resultSlice, "mutation")
-		var mutationName string
+// readFlow :
+// - Verifies the signed state cookie
+// - Returns the flow it holds
+func readFlow(config *authUtils.Config, cookie *http.Cookie) (flow, error) {
+	value, err := authUtils.DecodeValue(config, cookie.Value)
+	if err != nil {
+		return flow{}, err
+	}
+	f, ok := decodeFlow(value)
+	if !ok {
+		return flow{}, authUtils.ErrInvalidCookie
+	}
+	return f, nil
+}
 
-		// Does our query contain mutation?
-		if isMutation {
-			mutationName = resultSlice[1]
-			if mutationName == config.TriggerMutation {
-				return true
-			}
+// CallbackStateHandler :
+// - Goes on the callback route, before CallbackHandler
+// - Verifies the signed state cookie, reads the state, verifier, provider and returnTo of the flow and adds them to ctx
+// - Registry.CallbackHandler rejects callbacks for another provider than the one of the flow
+// - Missing, forged, tampered or expired cookies are passed to the failure handler
+func CallbackStateHandler(config *authUtils.Config, success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := CookieConfigToContext(req.Context(), config)
+
+		cookie, err := req.Cookie(config.Name)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, ErrMissingState)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
 		}
+		f, err := readFlow(config, cookie)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = StateToContext(ctx, f.State)
+		ctx = VerifierToContext(ctx, f.Verifier)
+		ctx = FlowProviderToContext(ctx, f.Provider)
+		ctx = ReturnToToContext(ctx, f.ReturnTo)
+		success.ServeHTTP(w, req.WithContext(ctx))
 	}
-	return false
+	return http.HandlerFunc(fn)
 }
 
-// StateCookieHandler :
-// - Oauth2 requires a state
-// - If state cookie exists, read its value and add to ctx var
-// - Otherwise generate a random value and add it to ctx
+// LoginStateHandler :
+// - Goes on the login route (or on /graphql), before LoginHandler
+// - Oauth2 requires a state, PKCE requires a code verifier, generated alongside the state for each flow
+// - A new flow starts: generate a random state and verifier and add them to ctx, LoginHandler saves the state
+// in the StateStore of config once the provider is known
+// - They are kept in a state cookie, signed (and optionally encrypted) with config.Keyring
+// - The flow is bound to the provider named by the provider argument of the trigger mutation (or of its input)
+// or by the ProviderParam query parameter, if any, see Registry
+// - A new flow may carry a returnTo URL, the returnTo argument of the trigger mutation (or of its input) or the returnTo
+// query parameter. It's checked with ValidReturnTo and bound to the flow in the signed cookie, see ReturnToHandler
 // - Takes four args:
 //		1- your auth config
 //		2- success is the function that is called after successful state management
 //		3- normalQuery bypasses the success function if it should not get called (means it's not the mutation that triggers oauth)
-func StateCookieHandler(config *authUtils.Config, success http.Handler, normalQuery http.Handler) http.Handler {
+//		4- failure is called when the flow can't start
+func LoginStateHandler(config *authUtils.Config, success http.Handler, normalQuery http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
 	fn := func(w http.ResponseWriter, req *http.Request) {
-		ctx := req.Context()
-		cookie, err := req.Cookie(config.Name)
-
-		if err == nil { // No error means we have a cookie -> add it to our state
-			ctx = StateToContext(ctx, cookie.Value)
-		} else {
-			// Generate a random state and store it in cookie
-			val := randomState()
-			http.SetCookie(w, authUtils.NewCookie(config, val))
-			ctx = StateToContext(ctx, val)
-		}
+		ctx := CookieConfigToContext(req.Context(), config)
 
 		// Now here below, let's see what we should return
 		// If we're dealing with the triggerMutation, then we should continue with success handler
 		// Otherwise we should continue with the relay handler
 		// Read the content
-		buf, _ := ioutil.ReadAll(req.Body)
+		var buf []byte
+		if req.Body != nil {
+			buf, _ = ioutil.ReadAll(req.Body)
+		}
 
 		rdr1 := ioutil.NopCloser(bytes.NewBuffer(buf))
 		rdr2 := ioutil.NopCloser(bytes.NewBuffer(buf))
 		// Restore the to its original state
 		req.Body = rdr2
 		// manipulate rd1 only
-		// log.Printf("BODY: %q", rdr1)
 		decoder := json.NewDecoder(rdr1)
 
 		var t struct {
-			Query string `json:"query"`
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
 		}
 		decoder.Decode(&t)
 
-		// Todo replace string by a value that should be defined in the config
-		// If our mutation name is the one that should trigger,
-		// we continue the HandlerFunc chain success.serveHTTP
-		if isTrigger(config, t.Query) || normalQuery == nil {
-			success.ServeHTTP(w, req.WithContext(ctx))
-		} else {
+		// If the selected operation doesn't call one of the trigger mutations,
+		// we continue with normalQuery and no flow is started
+		if normalQuery != nil && !isTrigger(config, t.Query, t.OperationName) {
 			normalQuery.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		// Where to go once logged in, bound to the flow so it can't be swapped on the callback
+		returnTo := triggerArgument(config, t.Query, t.OperationName, t.Variables, "returnTo")
+		if returnTo == "" {
+			returnTo = req.URL.Query().Get("returnTo")
+		}
+		if err := ValidReturnTo(config, returnTo); err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		// Each login gets its own state, the previous pending one can't be used anymore
+		if cookie, err := req.Cookie(config.Name); err == nil {
+			if old, err := readFlow(config, cookie); err == nil {
+				stateStore(config).Expire(ctx, old.State)
+			}
 		}
 
+		// The provider of a Registry is bound to the flow, a callback can't switch to another one
+		provider := triggerArgument(config, t.Query, t.OperationName, t.Variables, ProviderParam)
+		if provider == "" {
+			provider = req.URL.Query().Get(ProviderParam)
+		}
+
+		// Generate a random state and verifier and store them in a signed cookie
+		state, verifier := randomState(), oauth2.GenerateVerifier()
+		cookie, err := authUtils.NewSecureCookie(config, encodeFlow(flow{State: state, Verifier: verifier, Provider: provider, ReturnTo: returnTo}))
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.SetCookie(w, cookie)
+
+		ctx = StateToContext(ctx, state)
+		ctx = VerifierToContext(ctx, verifier)
+		if provider != "" {
+			ctx = ProviderNameToContext(ctx, provider)
+		}
+		ctx = ReturnToToContext(ctx, returnTo)
+		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
+// saveState saves state in the StateStore of the cookie config of ctx, until the state cookie expires
+func saveState(ctx context.Context, state string) error {
+	config, err := CookieConfigFromContext(ctx)
+	if err != nil {
+		return DefaultStateStore.Save(ctx, state, authUtils.DefaultCookieTTL)
+	}
+	return stateStore(config).Save(ctx, state, config.TTL())
+}
+
 // LoginHandler :
-// - Reads the state value from ctx
+// - Reads the state and code verifier values from ctx
+// - Saves the state in the StateStore of the cookie config of ctx, requests that fail before
+// (like an unknown provider of a Registry) leave nothing in the store
+// - The returnTo URL is already bound to the flow by LoginStateHandler, pass it as the returnTo argument
+// of the trigger mutation or as the returnTo query parameter of a redirect login
+// - Builds the AuthURL with the state and the S256 code challenge (PKCE)
 // - Executes success function if passed
-// - Otherwise redirects requests to the AuthURL with the state value.
+// - Otherwise redirects requests to the AuthURL.
 func LoginHandler(config *oauth2.Config, success http.Handler, failure http.Handler) http.Handler {
+	return loginHandler(config, nil, success, failure)
+}
+
+// ProviderLoginHandler :
+// - Is LoginHandler for the oauth2 config of provider
+// - Adds the AuthCodeOptions of provider to the AuthURL if it has any, like the nonce of OpenID Connect providers
+func ProviderLoginHandler(provider Provider, success http.Handler, failure http.Handler) http.Handler {
+	var options func(ctx context.Context) []oauth2.AuthCodeOption
+	if optioner, ok := provider.(AuthCodeOptioner); ok {
+		options = optioner.AuthCodeOptions
+	}
+	return loginHandler(provider.Config(), options, success, failure)
+}
+
+// loginHandler is LoginHandler, options returns the extra parameters of the AuthURL when not nil
+func loginHandler(config *oauth2.Config, options func(ctx context.Context) []oauth2.AuthCodeOption, success http.Handler, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = authUtils.DefaultFailureHandler
 	}
@@ -142,7 +278,24 @@ func LoginHandler(config *oauth2.Config, success http.Handler, failure http.Hand
 			return
 		}
 
-		authURL := config.AuthCodeURL(state)
+		verifier, err := VerifierFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		err = saveState(ctx, state)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+		if options != nil {
+			opts = append(opts, options(ctx)...)
+		}
+		authURL := config.AuthCodeURL(state, opts...)
 		ctx = AuthURLToContext(ctx, authURL)
 
 		// If no success handler is passed, use the default redirection
@@ -156,9 +309,36 @@ func LoginHandler(config *oauth2.Config, success http.Handler, failure http.Hand
 	return http.HandlerFunc(fn)
 }
 
+// consumeState :
+// - Checks state from the request against the state from ctx
+// - Consumes it from the StateStore of the cookie config of ctx, so a callback URL can't be replayed
+func consumeState(ctx context.Context, state string) error {
+	ownerState, err := StateFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if state != ownerState || state == "" {
+		return ErrInvalidState
+	}
+	// A state can only be used once
+	config, _ := CookieConfigFromContext(ctx)
+	return stateStore(config).Consume(ctx, state)
+}
+
+// clearStateCookie expires the state cookie, the flow is over
+func clearStateCookie(w http.ResponseWriter, ctx context.Context) {
+	if cookieConfig, err := CookieConfigFromContext(ctx); err == nil {
+		http.SetCookie(w, authUtils.ExpiredCookie(cookieConfig))
+	}
+}
+
 // CallbackHandler :
-// - Checks for a state cookie
-// - Adds state value to ctx
+// - Checks the state from the request against the state from ctx
+// - Consumes the state from the StateStore of the config, so a callback URL can't be replayed
+// - If the provider answered with an error (the user cancelled for instance), the failure
+// handler gets a *ProviderError, once the state is checked
+// - Exchanges the code for a token, sending the PKCE code verifier from ctx
+// - Clears the state cookie and adds token to ctx
 func CallbackHandler(config *oauth2.Config, success http.Handler, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = authUtils.DefaultFailureHandler
@@ -166,33 +346,49 @@ func CallbackHandler(config *oauth2.Config, success http.Handler, failure http.H
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 
+		// Only a provider error of our own flow is reported, others are invalid states
+		if providerErr := ProviderErrorFromReq(req); providerErr != nil {
+			err := consumeState(ctx, req.Form.Get("state"))
+			if err == nil {
+				clearStateCookie(w, ctx)
+				err = providerErr
+			}
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
 		authCode, state, err := StateAndCodeFromReq(req)
 		if err != nil {
 			ctx = authUtils.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		ownerState, err := StateFromContext(ctx)
+		err = consumeState(ctx, state)
 		if err != nil {
 			ctx = authUtils.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 
-		if state != ownerState || state == "" {
-			ctx = authUtils.WithError(ctx, ErrInvalidState)
+		verifier, err := VerifierFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 
-		// Ask for a token with the authorization code
-		token, err := config.Exchange(ctx, authCode)
+		// Ask for a token with the authorization code and the code verifier
+		token, err := config.Exchange(ctx, authCode, oauth2.VerifierOption(verifier))
 		if err != nil {
 			ctx = authUtils.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 
+		// The flow is over, clear the state cookie
+		clearStateCookie(w, ctx)
+
 		ctx = TokenToContext(ctx, token)
 		success.ServeHTTP(w, req.WithContext(ctx))
 
@@ -1,13 +1,18 @@
 package authCommon
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/astenmies/graphql-go-auth/authUtils"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func AssertSuccess(t *testing.T) http.Handler {
@@ -17,7 +22,7 @@ func AssertSuccess(t *testing.T) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-func Test_StateCookieHandler(t *testing.T) {
+func Test_LoginStateHandler(t *testing.T) {
 	config := &authUtils.Config{
 		Name:     "gqlauth_cookie",
 		Domain:   "dom",
@@ -28,12 +33,256 @@ func Test_StateCookieHandler(t *testing.T) {
 	}
 
 	success := AssertSuccess(t)
-	StateCookieHandler := StateCookieHandler(config, success)
+	LoginStateHandler := LoginStateHandler(config, success, nil, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 
-	StateCookieHandler.ServeHTTP(w, req)
+	LoginStateHandler.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "Success handler called", w.Body.String())
 }
+
+func Test_StateHandlers_Verifier(t *testing.T) {
+	config := &authUtils.Config{Name: "gqlauth_cookie", Path: "/"}
+
+	var state, verifier string
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+		verifier, _ = VerifierFromContext(req.Context())
+	})
+	handler := LoginStateHandler(config, success, nil, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.NotEmpty(t, state)
+	assert.NotEmpty(t, verifier)
+
+	// The callback reads the same flow back from the cookie
+	cookie := w.Result().Cookies()[0]
+	firstState, firstVerifier := state, verifier
+	req, _ = http.NewRequest("GET", "/callback?state="+firstState, nil)
+	req.AddCookie(cookie)
+	CallbackStateHandler(config, success, nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, firstState, state)
+	assert.Equal(t, firstVerifier, verifier)
+
+	// Another login starts a new flow and expires the previous state
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.NotEqual(t, firstState, state)
+	assert.NotEqual(t, firstVerifier, verifier)
+	assert.Equal(t, ErrUnknownState, DefaultStateStore.Consume(req.Context(), firstState))
+}
+
+func Test_CallbackStateHandler_Forged(t *testing.T) {
+	config := &authUtils.Config{Name: "gqlauth_cookie", Path: "/"}
+	handler := CallbackStateHandler(config, AssertSuccess(t), nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?state=state", nil)
+	req.AddCookie(&http.Cookie{Name: "gqlauth_cookie", Value: encodeFlow(flow{State: "state", Verifier: "verifier"})})
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), authUtils.ErrInvalidCookie.Error())
+}
+
+func Test_StateHandlers_ExplicitMode(t *testing.T) {
+	config := &authUtils.Config{Name: "gqlauth_cookie", Path: "/"}
+
+	// A state parameter on the login route doesn't make it a callback
+	var state string
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login?state=whatever", nil)
+	LoginStateHandler(config, success, nil, nil).ServeHTTP(w, req)
+	assert.NotEmpty(t, state)
+	assert.NotEqual(t, "whatever", state)
+	assert.Len(t, w.Result().Cookies(), 1)
+
+	// A callback without state parameter doesn't start a new flow
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?error=access_denied", nil)
+	CallbackStateHandler(config, AssertSuccess(t), nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrMissingState.Error())
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func Test_LoginHandler_PKCE(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://provider.example/auth"},
+	}
+	handler := LoginHandler(config, nil, nil)
+
+	ctx := StateToContext(context.Background(), "state")
+	ctx = VerifierToContext(ctx, "verifier")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, "state", location.Query().Get("state"))
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier("verifier"), location.Query().Get("code_challenge"))
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	// Only OpenID Connect providers get a nonce
+	assert.Empty(t, location.Query().Get("nonce"))
+}
+
+type oidcProvider struct {
+	fakeProvider
+}
+
+func (p *oidcProvider) AuthCodeOptions(ctx context.Context) []oauth2.AuthCodeOption {
+	return NonceOptions(ctx)
+}
+
+func Test_ProviderLoginHandler_Nonce(t *testing.T) {
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://provider.example/auth"}}
+	ctx := StateToContext(context.Background(), "state")
+	ctx = VerifierToContext(ctx, "verifier")
+	login := func(provider Provider) url.Values {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ProviderLoginHandler(provider, nil, nil).ServeHTTP(w, req.WithContext(ctx))
+		location, _ := url.Parse(w.Header().Get("Location"))
+		return location.Query()
+	}
+
+	assert.Equal(t, NonceForVerifier("verifier"), login(&oidcProvider{fakeProvider{name: "oidc", config: config}}).Get("nonce"))
+	query := login(&fakeProvider{name: "github", config: config})
+	assert.Empty(t, query.Get("nonce"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+}
+
+func newTokenServer(t *testing.T, gotVerifier *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		*gotVerifier = req.Form.Get("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"bearer"}`)
+	}))
+}
+
+func Test_CallbackHandler_PKCE(t *testing.T) {
+	var gotVerifier string
+	server := newTokenServer(t, &gotVerifier)
+	defer server.Close()
+
+	config := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL},
+	}
+	handler := CallbackHandler(config, AssertSuccess(t), nil)
+
+	ctx := StateToContext(context.Background(), "pkce-state")
+	ctx = VerifierToContext(ctx, "verifier")
+	DefaultStateStore.Save(ctx, "pkce-state", time.Minute)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?code=code&state=pkce-state", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, "Success handler called", w.Body.String())
+	assert.Equal(t, "verifier", gotVerifier)
+}
+
+func Test_CallbackHandler_SingleUse(t *testing.T) {
+	var gotVerifier string
+	server := newTokenServer(t, &gotVerifier)
+	defer server.Close()
+
+	config := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL},
+	}
+	cookieConfig := &authUtils.Config{Name: "gqlauth_cookie", Path: "/"}
+	handler := CallbackHandler(config, AssertSuccess(t), nil)
+
+	ctx := StateToContext(context.Background(), "single-use-state")
+	ctx = VerifierToContext(ctx, "verifier")
+	ctx = CookieConfigToContext(ctx, cookieConfig)
+	DefaultStateStore.Save(ctx, "single-use-state", time.Minute)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?code=code&state=single-use-state", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "Success handler called", w.Body.String())
+
+	// The state cookie is cleared
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "gqlauth_cookie", cookies[0].Name)
+	assert.True(t, cookies[0].MaxAge < 0)
+
+	// Replaying the callback URL fails
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrUnknownState.Error())
+}
+
+func Test_CallbackHandler_ProviderError(t *testing.T) {
+	config := &oauth2.Config{ClientID: "client"}
+	cookieConfig := &authUtils.Config{Name: "gqlauth_cookie", Path: "/"}
+	var got error
+	failure := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = authUtils.ErrorFromContext(req.Context())
+	})
+	handler := CallbackHandler(config, AssertSuccess(t), failure)
+
+	ctx := StateToContext(context.Background(), "cancelled-state")
+	ctx = VerifierToContext(ctx, "verifier")
+	ctx = CookieConfigToContext(ctx, cookieConfig)
+	DefaultStateStore.Save(ctx, "cancelled-state", time.Minute)
+
+	// Errors of other flows are invalid states
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?error=access_denied&state=other", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, ErrInvalidState, got)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?error=access_denied&error_description=The+user+cancelled&state=cancelled-state", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	var providerErr *ProviderError
+	assert.True(t, errors.As(got, &providerErr))
+	assert.Equal(t, "access_denied", providerErr.Code)
+	assert.Equal(t, "The user cancelled", providerErr.Description)
+	assert.True(t, errors.Is(got, ErrAccessDenied))
+	code, status := authUtils.ErrorCode(got)
+	assert.Equal(t, authUtils.CodeAccessDenied, code)
+	assert.Equal(t, http.StatusForbidden, status)
+	// The flow is over
+	assert.True(t, w.Result().Cookies()[0].MaxAge < 0)
+	assert.Equal(t, ErrUnknownState, DefaultStateStore.Consume(ctx, "cancelled-state"))
+
+	assert.True(t, errors.Is(&ProviderError{Code: "temporarily_unavailable"}, ErrProviderUnavailable))
+	assert.True(t, errors.Is(&ProviderError{Code: "invalid_scope"}, ErrProviderRejected))
+}
+
+func Test_StateHandlers_ConfigStore(t *testing.T) {
+	store := NewMemoryStateStore()
+	config := &authUtils.Config{Name: "gqlauth_cookie", Path: "/", StateStore: store}
+
+	var state string
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	})
+	req, _ := http.NewRequest("GET", "/login", nil)
+	login := LoginHandler(&oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://provider.example/auth"}}, success, nil)
+	LoginStateHandler(config, login, nil, nil).ServeHTTP(httptest.NewRecorder(), req)
+
+	// The state is in the store of the config, not in DefaultStateStore
+	ctx := CookieConfigToContext(context.Background(), config)
+	ctx = StateToContext(ctx, state)
+	assert.Equal(t, ErrUnknownState, DefaultStateStore.Consume(context.Background(), state))
+	assert.NoError(t, consumeState(ctx, state))
+	assert.Equal(t, ErrUnknownState, store.Consume(ctx, state))
+}
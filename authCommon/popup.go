@@ -0,0 +1,180 @@
+package authCommon
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Error messages
+var (
+	ErrInvalidOrigin = authUtils.NewError("oauth2: invalid origin, want scheme://host[:port]", authUtils.CodeBadRequest)
+)
+
+// PopupSource tells the messages of the popup apart from the other messages of the opener
+const PopupSource = "graphql-go-auth"
+
+// PopupUser is the user summary the popup sends to the opener
+type PopupUser struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Picture  string `json:"picture,omitempty"`
+}
+
+// PopupMessage :
+// - Is posted to window.opener
+// - Type is "login" with the User (and the Response of the CallbackQuery), or "error" with the Code, see authUtils.ErrorCode
+type PopupMessage struct {
+	Source   string            `json:"source"`
+	Type     string            `json:"type"`
+	User     *PopupUser        `json:"user,omitempty"`
+	Response *graphql.Response `json:"response,omitempty"`
+	Code     string            `json:"code,omitempty"`
+}
+
+// popupPage is what the Template of a Popup renders
+type popupPage struct {
+	Origins []string
+	Message *PopupMessage
+}
+
+// DefaultPopupTemplate :
+// - Posts the message to window.opener, for each allowed origin
+// - The browser only delivers it if the origin of the opener matches, other origins never see it
+// - Then closes itself
+var DefaultPopupTemplate = template.Must(template.New("popup").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign-in</title></head>
+<body>
+<p>{{if eq .Message.Type "login"}}You are authenticated{{else}}Sign-in failed{{end}}, you can close this window.</p>
+<script>
+(function () {
+	var message = {{.Message}};
+	var origins = {{.Origins}};
+	if (!window.opener) {
+		return;
+	}
+	for (var i = 0; i < origins.length; i++) {
+		window.opener.postMessage(message, origins[i]);
+	}
+	window.close();
+})();
+</script>
+</body>
+</html>
+`))
+
+// Popup :
+// - Ends a login flow opened in a popup window: the SPA opens the URL returned by the trigger mutation
+// with window.open and listens to "message" events
+// - Origins are the allowed origins of the opener, like https://app.example.com
+type Popup struct {
+	Origins  []string
+	Template *template.Template
+}
+
+// normalizeOrigin returns origin as scheme://host[:port], or ErrInvalidOrigin
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidOrigin, origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// NewPopup :
+// - Returns a Popup posting to the given origins, with DefaultPopupTemplate
+// - Wildcards are not allowed, each origin must be scheme://host[:port]
+func NewPopup(origins ...string) (*Popup, error) {
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("%w: no origin", ErrInvalidOrigin)
+	}
+	popup := &Popup{Template: DefaultPopupTemplate}
+	for _, origin := range origins {
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		popup.Origins = append(popup.Origins, normalized)
+	}
+	return popup, nil
+}
+
+func (p *Popup) render(w http.ResponseWriter, status int, message *PopupMessage) error {
+	message.Source = PopupSource
+	var page bytes.Buffer
+	err := p.Template.Execute(&page, &popupPage{Origins: p.Origins, Message: message})
+	if err != nil {
+		return err
+	}
+	// Never cache the result of a login
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.WriteTo(w)
+	return nil
+}
+
+// Success :
+// - Comes last on the callback route, like CallbackSuccess
+// - Posts a "login" message with a summary of the user of ctx
+// - When query is not nil, it runs and its response is added to the message
+// - The failure handler is called when ctx has no user
+func (p *Popup) Success(query *CallbackQuery, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = p.Failure()
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		message := &PopupMessage{
+			Type: "login",
+			User: &PopupUser{
+				Provider: user.Provider,
+				Subject:  user.Subject,
+				Email:    user.Email,
+				Name:     user.Name,
+				Picture:  user.Picture,
+			},
+		}
+		if query != nil {
+			message.Response = query.exec(ctx)
+		}
+		if err := p.render(w, http.StatusOK, message); err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			authUtils.DefaultFailureHandler.ServeHTTP(w, req.WithContext(ctx))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Failure :
+// - Is the failure handler of the callback route in popup mode
+// - Posts an "error" message with the code of the error of ctx, like ACCESS_DENIED when the user cancelled
+// - The error message is left out, it may hold provider details
+func (p *Popup) Failure() http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		code, status := authUtils.ErrorCode(authUtils.ErrorFromContext(ctx))
+		if err := p.render(w, status, &PopupMessage{Type: "error", Code: code}); err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			authUtils.DefaultFailureHandler.ServeHTTP(w, req.WithContext(ctx))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
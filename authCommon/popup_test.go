@@ -0,0 +1,54 @@
+package authCommon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewPopup(t *testing.T) {
+	popup, err := NewPopup("https://App.example.com", "http://localhost:8080/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://app.example.com", "http://localhost:8080"}, popup.Origins)
+
+	for _, origin := range []string{"*", "app.example.com", "javascript://x", "https://app.example.com/path", "https://app.example.com?x=1"} {
+		_, err := NewPopup(origin)
+		assert.True(t, errors.Is(err, ErrInvalidOrigin), origin)
+	}
+	_, err = NewPopup()
+	assert.True(t, errors.Is(err, ErrInvalidOrigin))
+}
+
+func Test_Popup(t *testing.T) {
+	popup, _ := NewPopup("https://app.example.com")
+	user := &User{Provider: "google", Subject: "42", Name: "Bob", Email: "bob@example.com"}
+	ctx := UserToContext(context.Background(), user)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	query := &CallbackQuery{Schema: callbackSchema, Query: "{ me }"}
+	popup.Success(query, nil).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	body := w.Body.String()
+	assert.Contains(t, body, `var message = {"source":"graphql-go-auth","type":"login","user":{"provider":"google","subject":"42","email":"bob@example.com","name":"Bob"},"response":{"data":{"me":"Bob"}}};`)
+	assert.Contains(t, body, `var origins = ["https://app.example.com"];`)
+
+	// No user
+	w = httptest.NewRecorder()
+	popup.Success(nil, nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"error","code":"UNAUTHENTICATED"`)
+
+	w = httptest.NewRecorder()
+	ctx = authUtils.WithError(context.Background(), &ProviderError{Code: "access_denied", Description: "secret details"})
+	popup.Failure().ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"ACCESS_DENIED"`)
+	assert.NotContains(t, w.Body.String(), "secret details")
+}
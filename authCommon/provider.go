@@ -0,0 +1,210 @@
+package authCommon
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+// Error messages
+var (
+	ErrUnknownProvider  = authUtils.NewError("oauth2: Unknown provider", authUtils.CodeBadRequest)
+	ErrProviderMismatch = authUtils.NewError("oauth2: The callback is not for the provider of the flow", authUtils.CodeInvalidState)
+)
+
+// ProviderParam is the query parameter that selects the provider of a Registry
+const ProviderParam = "provider"
+
+// Provider :
+// - An identity source usable with LoginHandler and CallbackHandler
+// - Name identifies the provider in a Registry, in ctx and in User.Provider
+// - Config returns its oauth2 config
+// - User fetches the normalized user the token belongs to
+type Provider interface {
+	Name() string
+	Config() *oauth2.Config
+	User(ctx context.Context, token *oauth2.Token) (*User, error)
+}
+
+// Revoker :
+// - Implemented by providers that can revoke their tokens, see authLogout
+// - Revoking the refresh token also revokes the access tokens issued with it
+type Revoker interface {
+	Revoke(ctx context.Context, token *oauth2.Token) error
+}
+
+// AuthCodeOptioner :
+// - Implemented by providers that need more parameters on the auth URL than the state and the PKCE challenge
+// - OpenID Connect providers return NonceOptions, other providers would never send the nonce back
+type AuthCodeOptioner interface {
+	AuthCodeOptions(ctx context.Context) []oauth2.AuthCodeOption
+}
+
+// ProviderHandler :
+// - Gets the OAuth2 Token from the ctx
+// - Then gets the User from provider with token
+// - Adds user to the ctx and the success handler is called
+// - Otherwise, the failure handler is called
+func ProviderHandler(provider Provider, success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := TokenFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user, err := provider.User(ctx, token)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = UserToContext(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Registry :
+// - Holds providers by name
+// - Serves login and callback routes shared by all of them
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewRegistry returns a Registry holding providers
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds provider, replacing any provider with the same name
+func (r *Registry) Register(provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+// Get returns the provider registered under name
+func (r *Registry) Get(name string) (Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names returns the sorted names of the registered providers
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultName returns name, or the name of the only registered provider when name is empty
+func (r *Registry) defaultName(name string) string {
+	if name == "" {
+		if names := r.Names(); len(names) == 1 {
+			name = names[0]
+		}
+	}
+	return name
+}
+
+// providerFor :
+// - Returns the provider named in ctx (see ProviderNameToContext)
+// - Or the one named by the ProviderParam query parameter
+// - Or the only registered provider
+func (r *Registry) providerFor(req *http.Request) (Provider, error) {
+	name, err := ProviderNameFromContext(req.Context())
+	if err != nil {
+		name = req.URL.Query().Get(ProviderParam)
+	}
+	return r.Get(r.defaultName(name))
+}
+
+// callbackProvider :
+// - Returns the provider the flow of the callback started with (see CallbackStateHandler),
+// or the only registered provider
+// - The ProviderParam query parameter is not signed, it must name the same provider,
+// otherwise a code of one provider could be sent to the token endpoint of another one
+func (r *Registry) callbackProvider(req *http.Request) (Provider, error) {
+	name, err := FlowProviderFromContext(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	name = r.defaultName(name)
+	if param := req.URL.Query().Get(ProviderParam); param != "" && param != name {
+		return nil, ErrProviderMismatch
+	}
+	return r.Get(name)
+}
+
+// LoginHandler :
+// - Selects the provider of the request
+// - Adds its name to ctx
+// - Continues with ProviderLoginHandler for this provider
+func (r *Registry) LoginHandler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		provider, err := r.providerFor(req)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = ProviderNameToContext(ctx, provider.Name())
+		ProviderLoginHandler(provider, success, failure).ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandler :
+// - Selects the provider the flow started with, the callback must come after CallbackStateHandler
+// - Callbacks naming another provider fail with ErrProviderMismatch
+// - Adds its name to ctx
+// - Continues with CallbackHandler and ProviderHandler for this provider
+// - Register each provider with a RedirectURL that selects it, like /callback?provider=google
+func (r *Registry) CallbackHandler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		provider, err := r.callbackProvider(req)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = ProviderNameToContext(ctx, provider.Name())
+		handleUser := ProviderHandler(provider, success, failure)
+		CallbackHandler(provider.Config(), handleUser, failure).ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,56 @@
+package authCommon
+
+import (
+	"net/http"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error messages
+var (
+	ErrAccessDenied        = authUtils.NewError("oauth2: the user denied access", authUtils.CodeAccessDenied)
+	ErrProviderUnavailable = authUtils.NewError("oauth2: the provider is unavailable", authUtils.CodeUpstreamError)
+	ErrProviderRejected    = authUtils.NewError("oauth2: the provider rejected the authorization request", authUtils.CodeBadRequest)
+)
+
+// ProviderError :
+// - An error response of the authorization endpoint (RFC 6749, section 4.1.2.1)
+// - Code is the error parameter, like "access_denied" when the user clicks "Cancel"
+// - It wraps ErrAccessDenied, ErrProviderUnavailable or ErrProviderRejected, use errors.Is
+type ProviderError struct {
+	Code        string
+	Description string
+	URI         string
+}
+
+func (e *ProviderError) Error() string {
+	msg := "oauth2: provider error " + e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+func (e *ProviderError) Unwrap() error {
+	switch e.Code {
+	case "access_denied":
+		return ErrAccessDenied
+	case "server_error", "temporarily_unavailable":
+		return ErrProviderUnavailable
+	}
+	return ErrProviderRejected
+}
+
+// ProviderErrorFromReq returns the error the provider redirected back with, nil if there is none
+func ProviderErrorFromReq(req *http.Request) *ProviderError {
+	req.ParseForm()
+	code := req.Form.Get("error")
+	if code == "" {
+		return nil
+	}
+	return &ProviderError{
+		Code:        code,
+		Description: req.Form.Get("error_description"),
+		URI:         req.Form.Get("error_uri"),
+	}
+}
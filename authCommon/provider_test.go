@@ -0,0 +1,116 @@
+package authCommon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type fakeProvider struct {
+	name   string
+	config *oauth2.Config
+}
+
+func (p *fakeProvider) Name() string           { return p.name }
+func (p *fakeProvider) Config() *oauth2.Config { return p.config }
+func (p *fakeProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	return &User{Provider: p.name, Subject: token.AccessToken}, nil
+}
+
+func Test_Registry(t *testing.T) {
+	var gotVerifier string
+	server := newTokenServer(t, &gotVerifier)
+	defer server.Close()
+
+	newProvider := func(name string) *fakeProvider {
+		return &fakeProvider{name: name, config: &oauth2.Config{
+			ClientID: name,
+			Endpoint: oauth2.Endpoint{AuthURL: "https://" + name + ".example/auth", TokenURL: server.URL},
+		}}
+	}
+	registry := NewRegistry(newProvider("one"), newProvider("two"))
+	assert.Equal(t, []string{"one", "two"}, registry.Names())
+
+	// Login with the provider of the request
+	ctx := StateToContext(context.Background(), "registry-state")
+	ctx = VerifierToContext(ctx, "verifier")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login?provider=two", nil)
+	registry.LoginHandler(nil, nil).ServeHTTP(w, req.WithContext(ctx))
+	location, _ := url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, "two.example", location.Host)
+
+	// Callback with the provider of the flow
+	var user *User
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _ = UserFromContext(req.Context())
+	})
+	DefaultStateStore.Save(ctx, "registry-state", time.Minute)
+	callbackCtx := FlowProviderToContext(ctx, "two")
+	req, _ = http.NewRequest("GET", "/callback?provider=two&code=code&state=registry-state", nil)
+	registry.CallbackHandler(success, nil).ServeHTTP(httptest.NewRecorder(), req.WithContext(callbackCtx))
+	assert.Equal(t, &User{Provider: "two", Subject: "token"}, user)
+
+	// A code can't be sent to the token endpoint of another provider (mix-up)
+	DefaultStateStore.Save(ctx, "registry-state", time.Minute)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?provider=one&code=code&state=registry-state", nil)
+	registry.CallbackHandler(success, nil).ServeHTTP(w, req.WithContext(callbackCtx))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrProviderMismatch.Error())
+
+	// The flow is bound to the provider named on login
+	config := &authUtils.Config{Name: "gqlauth_cookie", Path: "/"}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/login?provider=two", nil)
+	LoginStateHandler(config, registry.LoginHandler(nil, nil), nil, nil).ServeHTTP(w, req)
+	var flowProvider string
+	req, _ = http.NewRequest("GET", "/callback?provider=one&state=x", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	CallbackStateHandler(config, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		flowProvider, _ = FlowProviderFromContext(req.Context())
+	}), nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "two", flowProvider)
+
+	// Or by the trigger mutation
+	config.TriggerMutation = "triggerOauth"
+	w = httptest.NewRecorder()
+	body := `{"query":"mutation { triggerOauth(input: {provider: \"one\"}) }"}`
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	LoginStateHandler(config, registry.LoginHandler(nil, nil), nil, nil).ServeHTTP(w, req)
+	location, _ = url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, "one.example", location.Host)
+
+	// Several providers and none selected
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/login", nil)
+	registry.LoginHandler(nil, nil).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrUnknownProvider.Error())
+
+	// Unknown providers don't leave a state in the store
+	store := NewMemoryStateStore()
+	storeConfig := &authUtils.Config{Name: "gqlauth_cookie", Path: "/", StateStore: store}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/login?provider=unknown", nil)
+	LoginStateHandler(storeConfig, registry.LoginHandler(nil, nil), nil, nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, store.states)
+	req, _ = http.NewRequest("GET", "/login?provider=one", nil)
+	LoginStateHandler(storeConfig, registry.LoginHandler(nil, nil), nil, nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Len(t, store.states, 1)
+
+	// A single provider is selected by default
+	req, _ = http.NewRequest("GET", "/login", nil)
+	w = httptest.NewRecorder()
+	NewRegistry(newProvider("one")).LoginHandler(nil, nil).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+}
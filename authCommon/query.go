@@ -0,0 +1,116 @@
+package authCommon
+
+import (
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// selectOperation :
+// - Parses the GraphQL document
+// - Returns the operation selected by operationName
+// - Or the only operation of the document when operationName is empty
+// - Returns nil if the document is invalid or the operation can't be resolved
+func selectOperation(query, operationName string) (*ast.QueryDocument, *ast.OperationDefinition) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return nil, nil
+	}
+	if operationName == "" && len(doc.Operations) != 1 {
+		return nil, nil
+	}
+	op := doc.Operations.ForName(operationName)
+	if op == nil {
+		return nil, nil
+	}
+	return doc, op
+}
+
+// topLevelFields :
+// - Returns the root fields of a selection set
+// - Fragment spreads and inline fragments are expanded
+// - visited prevents infinite loops on cyclic fragments
+func topLevelFields(doc *ast.QueryDocument, set ast.SelectionSet, visited map[string]bool) []*ast.Field {
+	var fields []*ast.Field
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			fields = append(fields, s)
+		case *ast.InlineFragment:
+			fields = append(fields, topLevelFields(doc, s.SelectionSet, visited)...)
+		case *ast.FragmentSpread:
+			if visited[s.Name] {
+				continue
+			}
+			visited[s.Name] = true
+			if fragment := doc.Fragments.ForName(s.Name); fragment != nil {
+				fields = append(fields, topLevelFields(doc, fragment.SelectionSet, visited)...)
+			}
+		}
+	}
+	return fields
+}
+
+// mutationField :
+// - Returns the first top-level field of the selected operation whose name (not alias) is one of names
+// - Returns nil if the operation is not a mutation or calls none of them
+func mutationField(query, operationName string, names []string) *ast.Field {
+	if len(names) == 0 {
+		return nil
+	}
+	doc, op := selectOperation(query, operationName)
+	if op == nil || op.Operation != ast.Mutation {
+		return nil
+	}
+	for _, field := range topLevelFields(doc, op.SelectionSet, map[string]bool{}) {
+		if authUtils.Contains(names, field.Name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// selectsMutation :
+// - Returns true if the selected operation is a mutation
+// - and one of its top-level fields is one of names
+func selectsMutation(query, operationName string, names []string) bool {
+	return mutationField(query, operationName, names) != nil
+}
+
+// isTrigger :
+// - Returns true if the selected operation calls one of the trigger mutations of config
+func isTrigger(config *authUtils.Config, query, operationName string) bool {
+	return selectsMutation(query, operationName, config.TriggerMutationNames())
+}
+
+// triggerArgument :
+// - Returns the string argument name of the trigger mutation, like returnTo or provider, with the variables of the request
+// - It's either an argument of the mutation or a field of its input argument
+// - Returns "" when there is none
+func triggerArgument(config *authUtils.Config, query, operationName string, variables map[string]interface{}, name string) string {
+	field := mutationField(query, operationName, config.TriggerMutationNames())
+	if field == nil {
+		return ""
+	}
+	var raw interface{}
+	if argument := field.Arguments.ForName(name); argument != nil {
+		raw, _ = argument.Value.Value(variables)
+	} else if argument := field.Arguments.ForName("input"); argument != nil {
+		input, _ := argument.Value.Value(variables)
+		if object, ok := input.(map[string]interface{}); ok {
+			raw = object[name]
+		}
+	}
+	value, _ := raw.(string)
+	return value
+}
+
+// IsLogout :
+// - Returns true if the selected operation calls the logout mutation of config
+// - Detected like the trigger mutations
+func IsLogout(config *authUtils.Config, query, operationName string) bool {
+	if config.LogoutMutation == "" {
+		return false
+	}
+	return selectsMutation(query, operationName, []string{config.LogoutMutation})
+}
@@ -0,0 +1,46 @@
+package authCommon
+
+import (
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isTrigger(t *testing.T) {
+	config := &authUtils.Config{
+		TriggerMutation:  "triggerOauth",
+		TriggerMutations: []string{"triggerGithub"},
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		operationName string
+		want          bool
+	}{
+		{"anonymous", `mutation { triggerOauth(input: {username: "bob"}) }`, "", true},
+		{"named", `mutation Login { triggerOauth }`, "", true},
+		{"alias", `mutation { login: triggerOauth }`, "", true},
+		{"alias named like trigger", `mutation { triggerOauth: other }`, "", false},
+		{"comment", "# triggerOauth\nmutation {\n  # comment\n  triggerOauth\n}", "", true},
+		{"second trigger", `mutation { triggerGithub }`, "", true},
+		{"fragment", `mutation { ...F } fragment F on Mutation { triggerOauth }`, "", true},
+		{"inline fragment", `mutation { ... on Mutation { triggerOauth } }`, "", true},
+		{"operation name selects trigger", `query A { me } mutation B { triggerOauth }`, "B", true},
+		{"operation name selects query", `query A { me } mutation B { triggerOauth }`, "A", false},
+		{"several operations without name", `query A { me } mutation B { triggerOauth }`, "", false},
+		{"unknown operation name", `mutation B { triggerOauth }`, "C", false},
+		{"query", `query { triggerOauth }`, "", false},
+		{"other mutation", `mutation { other }`, "", false},
+		{"nested field", `mutation { other { triggerOauth } }`, "", false},
+		{"introspection text", `mutation IntrospectionQuery { triggerOauth }`, "", true},
+		{"invalid", `mutation { triggerOauth`, "", false},
+		{"empty", ``, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTrigger(config, tt.query, tt.operationName))
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package authCommon
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error messages
+var (
+	ErrInvalidReturnTo = authUtils.NewError("oauth2: returnTo is not an allowed destination", authUtils.CodeBadRequest)
+)
+
+// allowedPath returns true if p is one of the paths of config, or below one of them
+func allowedPath(config *authUtils.Config, p string) bool {
+	if len(config.ReturnToPaths) == 0 {
+		return true
+	}
+	for _, prefix := range config.ReturnToPaths {
+		if p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedOrigin returns true if origin is one of the ReturnToOrigins of config
+func allowedOrigin(config *authUtils.Config, origin string) bool {
+	for _, allowed := range config.ReturnToOrigins {
+		if normalized, err := normalizeOrigin(allowed); err == nil && normalized == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidReturnTo :
+// - Checks that returnTo is a safe destination after login, to avoid open redirects
+// - A relative path ("/account") is allowed when it's in config.ReturnToPaths
+// - An absolute URL also needs its origin in config.ReturnToOrigins
+// - Scheme-relative URLs ("//evil.com"), backslashes, credentials and dot segments are refused
+// - Returns ErrInvalidReturnTo otherwise, an empty returnTo is valid
+func ValidReturnTo(config *authUtils.Config, returnTo string) error {
+	if returnTo == "" {
+		return nil
+	}
+	if strings.ContainsAny(returnTo, "\\\r\n\t") || strings.HasPrefix(returnTo, "//") {
+		return ErrInvalidReturnTo
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil || u.User != nil || u.Opaque != "" {
+		return ErrInvalidReturnTo
+	}
+	if u.Scheme != "" || u.Host != "" {
+		origin, err := normalizeOrigin(u.Scheme + "://" + u.Host)
+		if err != nil || !allowedOrigin(config, origin) {
+			return ErrInvalidReturnTo
+		}
+	} else if !strings.HasPrefix(u.Path, "/") {
+		return ErrInvalidReturnTo
+	}
+
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	// "/account/../admin" must not pass as "/account"
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	if cleaned != p {
+		return ErrInvalidReturnTo
+	}
+	if !allowedPath(config, p) {
+		return ErrInvalidReturnTo
+	}
+	return nil
+}
+
+// ReturnToHandler :
+// - Comes last on the callback route, once the login succeeded
+// - Redirects to the returnTo the flow started with, it's checked again with the cookie config of ctx
+// - Calls fallback when the flow has no (valid) returnTo
+func ReturnToHandler(fallback http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		returnTo, err := ReturnToFromContext(ctx)
+		if err != nil || returnTo == "" {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+		config, err := CookieConfigFromContext(ctx)
+		if err != nil || ValidReturnTo(config, returnTo) != nil {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+		http.Redirect(w, req, returnTo, http.StatusSeeOther)
+	}
+	return http.HandlerFunc(fn)
+}
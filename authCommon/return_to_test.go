@@ -0,0 +1,89 @@
+package authCommon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidReturnTo(t *testing.T) {
+	config := &authUtils.Config{
+		ReturnToOrigins: []string{"https://app.example.com"},
+		ReturnToPaths:   []string{"/", "/account/"},
+	}
+	for _, returnTo := range []string{"", "/", "/dashboard?tab=1", "https://APP.example.com/account/settings", "https://app.example.com"} {
+		assert.Nil(t, ValidReturnTo(config, returnTo), returnTo)
+	}
+	for _, returnTo := range []string{
+		"//evil.com", "/\\evil.com", "https://evil.com/", "https://app.example.com.evil.com/",
+		"https://user@app.example.com/", "javascript:alert(1)", "dashboard", "/account/../../x",
+	} {
+		assert.Equal(t, ErrInvalidReturnTo, ValidReturnTo(config, returnTo), returnTo)
+	}
+
+	config.ReturnToPaths = []string{"/account"}
+	assert.Nil(t, ValidReturnTo(config, "/account/settings"))
+	assert.Equal(t, ErrInvalidReturnTo, ValidReturnTo(config, "/accounts"))
+	assert.Equal(t, ErrInvalidReturnTo, ValidReturnTo(config, "/admin"))
+}
+
+func Test_StateHandlers_ReturnTo(t *testing.T) {
+	config := &authUtils.Config{Name: "gqlauth_cookie", Path: "/", TriggerMutation: "triggerOauth"}
+
+	var returnTo string
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		returnTo, _ = ReturnToFromContext(req.Context())
+	})
+	handler := LoginStateHandler(config, success, AssertSuccess(t), nil)
+
+	// From the input of the trigger mutation, with variables
+	body := `{"query":"mutation ($to: String) { triggerOauth(input: {username: \"bob\", returnTo: $to}) }","variables":{"to":"/dashboard"}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "/dashboard", returnTo)
+
+	// The callback reads it back from the signed cookie
+	returnTo = ""
+	req, _ = http.NewRequest("GET", "/callback?state=x", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	CallbackStateHandler(config, success, nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "/dashboard", returnTo)
+
+	// From the query parameter of a redirect login
+	req, _ = http.NewRequest("GET", "/login?returnTo=%2Faccount", nil)
+	LoginStateHandler(config, success, nil, nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "/account", returnTo)
+
+	// Open redirects are refused before the flow starts
+	body = `{"query":"mutation { triggerOauth(returnTo: \"https://evil.com\") }"}`
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func Test_ReturnToHandler(t *testing.T) {
+	config := &authUtils.Config{ReturnToPaths: []string{"/account"}}
+	handler := ReturnToHandler(AssertSuccess(t))
+	req, _ := http.NewRequest("GET", "/callback", nil)
+
+	ctx := CookieConfigToContext(context.Background(), config)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ReturnToToContext(ctx, "/account/settings")))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/account/settings", w.Header().Get("Location"))
+
+	// No returnTo, or one the config doesn't allow anymore
+	for _, returnTo := range []string{"", "/admin"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(ReturnToToContext(ctx, returnTo)))
+		assert.Equal(t, "Success handler called", w.Body.String())
+	}
+}
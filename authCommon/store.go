@@ -0,0 +1,106 @@
+package authCommon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error messages
+var (
+	ErrUnknownState = authUtils.NewError("oauth2: Unknown, expired or already used state", authUtils.CodeInvalidState)
+)
+
+// StateStore :
+// - Keeps track of the pending OAuth2 flows on the server side
+// - Save registers a new state for ttl
+// - Consume removes a state and returns ErrUnknownState if it was unknown, expired or already consumed
+// - Expire removes a state without using it, for instance when the user starts another login
+// - Set it per config with authUtils.Config.StateStore
+type StateStore = authUtils.StateStore
+
+// DefaultStateStore :
+// - Used by LoginStateHandler and CallbackHandler when the config has no StateStore
+// - Replace it, or set authUtils.Config.StateStore, with a shared store when running several server instances
+var DefaultStateStore StateStore = NewMemoryStateStore()
+
+// stateStore returns the StateStore of config, or DefaultStateStore
+func stateStore(config *authUtils.Config) StateStore {
+	if config != nil && config.StateStore != nil {
+		return config.StateStore
+	}
+	return DefaultStateStore
+}
+
+// DefaultSweepInterval is how often a MemoryStateStore evicts its expired states
+const DefaultSweepInterval = time.Minute
+
+// MemoryStateStore :
+// - In-memory StateStore
+// - Expired states are evicted at most every SweepInterval, when states are saved or consumed
+type MemoryStateStore struct {
+	SweepInterval time.Duration
+
+	mu        sync.Mutex
+	states    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStateStore returns an empty MemoryStateStore
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		SweepInterval: DefaultSweepInterval,
+		states:        make(map[string]time.Time),
+		now:           time.Now,
+	}
+}
+
+// sweep evicts the expired states, once per SweepInterval
+func (s *MemoryStateStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.SweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for state, expiresAt := range s.states {
+		if !now.Before(expiresAt) {
+			delete(s.states, state)
+		}
+	}
+}
+
+// Save registers state until ttl elapses
+func (s *MemoryStateStore) Save(ctx context.Context, state string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	s.states[state] = now.Add(ttl)
+	return nil
+}
+
+// Consume removes state, it fails if state is unknown or expired
+func (s *MemoryStateStore) Consume(ctx context.Context, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(s.now())
+	expiresAt, ok := s.states[state]
+	if !ok {
+		return ErrUnknownState
+	}
+	delete(s.states, state)
+	if !s.now().Before(expiresAt) {
+		return ErrUnknownState
+	}
+	return nil
+}
+
+// Expire removes state
+func (s *MemoryStateStore) Expire(ctx context.Context, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, state)
+	return nil
+}
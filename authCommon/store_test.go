@@ -0,0 +1,47 @@
+package authCommon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MemoryStateStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+	clock := time.Unix(1000, 0)
+	store.now = func() time.Time { return clock }
+
+	store.Save(ctx, "a", time.Minute)
+	store.Save(ctx, "b", time.Minute)
+	store.Save(ctx, "c", time.Minute)
+
+	// Consumed only once
+	assert.NoError(t, store.Consume(ctx, "a"))
+	assert.Equal(t, ErrUnknownState, store.Consume(ctx, "a"))
+
+	// Expired on demand
+	store.Expire(ctx, "b")
+	assert.Equal(t, ErrUnknownState, store.Consume(ctx, "b"))
+
+	// Expired after ttl, and evicted by the next sweep
+	clock = clock.Add(2 * time.Minute)
+	assert.Equal(t, ErrUnknownState, store.Consume(ctx, "c"))
+	store.Save(ctx, "c", time.Minute)
+	store.Save(ctx, "d", time.Minute)
+	clock = clock.Add(2 * time.Minute)
+	store.Save(ctx, "e", time.Minute)
+	assert.Len(t, store.states, 1)
+
+	// Not swept again before SweepInterval
+	store.SweepInterval = 5 * time.Minute
+	clock = clock.Add(2 * time.Minute)
+	store.Save(ctx, "f", time.Minute)
+	assert.Len(t, store.states, 2)
+	clock = clock.Add(4 * time.Minute)
+	store.Save(ctx, "g", time.Minute)
+	assert.Len(t, store.states, 1)
+	assert.Equal(t, ErrUnknownState, store.Consume(ctx, "unknown"))
+}
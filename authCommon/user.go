@@ -0,0 +1,27 @@
+package authCommon
+
+// User :
+// - Identity of the authenticated user, normalized across providers
+// - Resolvers read it with UserFromContext, whatever the provider
+type User struct {
+	// Provider is the name of the provider that authenticated the user
+	Provider string `json:"provider"`
+	// Subject is the user id at the provider
+	Subject       string `json:"subject"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name,omitempty"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+	Locale        string `json:"locale,omitempty"`
+	// Raw holds the claims as returned by the provider
+	Raw map[string]interface{} `json:"raw,omitempty"`
+}
+
+// ID :
+// - Returns the id of the user across providers: its provider and subject, like "google:42"
+// - Sessions, stored tokens and roles are keyed by it
+func (u *User) ID() string {
+	return u.Provider + ":" + u.Subject
+}
@@ -0,0 +1,12 @@
+package authCommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_User_ID(t *testing.T) {
+	assert.Equal(t, "google:42", (&User{Provider: "google", Subject: "42"}).ID())
+	assert.NotEqual(t, (&User{Provider: "github", Subject: "42"}).ID(), (&User{Provider: "google", Subject: "42"}).ID())
+}
@@ -0,0 +1,246 @@
+package authDirective
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Directives declares @auth, @hasRole and @hasScope, add it to your schema
+const Directives = `
+directive @auth on OBJECT | FIELD_DEFINITION
+directive @hasRole(role: String!) on OBJECT | FIELD_DEFINITION
+directive @hasScope(scope: String!) on OBJECT | FIELD_DEFINITION
+`
+
+// Extension codes of the errors
+const (
+	CodeUnauthenticated  = authUtils.CodeUnauthenticated
+	CodeForbidden        = authUtils.CodeForbidden
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+)
+
+// Enforcer :
+// - Checks the @auth, @hasRole and @hasScope directives of a schema before a query runs
+// - Directives apply to a field definition, or to every field of an object type
+// - Roles and Scopes return the roles and scopes of the authCommon.User of the ctx
+type Enforcer struct {
+	Roles  func(ctx context.Context, user *authCommon.User) ([]string, error)
+	Scopes func(ctx context.Context, user *authCommon.User) ([]string, error)
+
+	schema *ast.Schema
+	policy Policy
+}
+
+// NewEnforcer :
+// - Parses the schema, the same SDL string given to graphql.MustParseSchema
+// - The schema must declare Directives
+// - Roles and scopes are read from the claims of the user, see RolesFromUser and ScopesFromUser
+func NewEnforcer(schema string) (*Enforcer, error) {
+	parsed, err := gqlparser.LoadSchema(&ast.Source{Name: "schema", Input: schema})
+	if err != nil {
+		return nil, err
+	}
+	return &Enforcer{Roles: RolesFromUser, Scopes: ScopesFromUser, schema: parsed}, nil
+}
+
+// stringList reads a claim holding a list of strings, or a space separated string
+func stringList(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []string:
+		return claim
+	case []interface{}:
+		list := make([]string, 0, len(claim))
+		for _, item := range claim {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// RolesFromUser returns the "roles" claim of user, as set by authJWT.Issuer.ClaimsFunc for instance
+func RolesFromUser(ctx context.Context, user *authCommon.User) ([]string, error) {
+	return stringList(user.Raw["roles"]), nil
+}
+
+// ScopesFromUser returns the "scope" (space separated) or "scp" claim of user
+func ScopesFromUser(ctx context.Context, user *authCommon.User) ([]string, error) {
+	if scopes := stringList(user.Raw["scope"]); scopes != nil {
+		return scopes, nil
+	}
+	return stringList(user.Raw["scp"]), nil
+}
+
+// principal computes the user, roles and scopes of a request once
+type principal struct {
+	ctx    context.Context
+	e      *Enforcer
+	user   *authCommon.User
+	roles  []string
+	scopes []string
+	err    error
+	loaded bool
+}
+
+func (p *principal) load() {
+	if p.loaded {
+		return
+	}
+	p.loaded = true
+	user, err := authCommon.UserFromContext(p.ctx)
+	if err != nil {
+		return
+	}
+	p.user = user
+	if p.roles, p.err = p.e.Roles(p.ctx, user); p.err != nil {
+		return
+	}
+	p.scopes, p.err = p.e.Scopes(p.ctx, user)
+}
+
+// denied returns the error of the first directive of directives p doesn't satisfy, nil if all are satisfied
+func (p *principal) denied(field string, directives ast.DirectiveList) *errors.QueryError {
+	for _, directive := range directives {
+		var name, required string
+		switch directive.Name {
+		case "auth":
+		case "hasRole":
+			name, required = "role", directive.Arguments.ForName("role").Value.Raw
+		case "hasScope":
+			name, required = "scope", directive.Arguments.ForName("scope").Value.Raw
+		default:
+			continue
+		}
+
+		p.load()
+		if p.user == nil {
+			return &errors.QueryError{
+				Message:    fmt.Sprintf("%s requires authentication", field),
+				Extensions: map[string]interface{}{"code": CodeUnauthenticated},
+			}
+		}
+		if name == "" {
+			continue
+		}
+		if p.err != nil {
+			return &errors.QueryError{
+				Err:        p.err,
+				Message:    fmt.Sprintf("%s requires the %s %q, the %ss of the user are unavailable", field, name, required, name),
+				Extensions: map[string]interface{}{"code": CodeForbidden},
+			}
+		}
+		granted := p.scopes
+		if name == "role" {
+			granted = p.roles
+		}
+		if !authUtils.Contains(granted, required) {
+			return &errors.QueryError{
+				Message:    fmt.Sprintf("%s requires the %s %q", field, name, required),
+				Extensions: map[string]interface{}{"code": CodeForbidden},
+			}
+		}
+	}
+	return nil
+}
+
+// Check :
+// - Validates query against the schema and walks the fields of the selected operation
+// - Returns an error for each field whose directives the user of ctx doesn't satisfy,
+// with the UNAUTHENTICATED or FORBIDDEN extension code
+// - Fields are checked even if @skip or @include would leave them out
+// - When the operation selects fields denied by the policy (see SetPolicy), one more error
+// rejects it, listing these fields in its "fields" extension
+func (e *Enforcer) Check(ctx context.Context, query string, operationName string) []*errors.QueryError {
+	doc, gqlErrs := gqlparser.LoadQuery(e.schema, query)
+	if len(gqlErrs) > 0 {
+		errs := make([]*errors.QueryError, 0, len(gqlErrs))
+		for _, gqlErr := range gqlErrs {
+			queryErr := &errors.QueryError{
+				Message:    gqlErr.Message,
+				Extensions: map[string]interface{}{"code": CodeValidationFailed},
+			}
+			for _, location := range gqlErr.Locations {
+				queryErr.Locations = append(queryErr.Locations, errors.Location{Line: location.Line, Column: location.Column})
+			}
+			errs = append(errs, queryErr)
+		}
+		return errs
+	}
+	op := doc.Operations.ForName(operationName)
+	if op == nil {
+		// graphql-go reports it, no resolver runs
+		return nil
+	}
+
+	p := &principal{ctx: ctx, e: e}
+	var errs []*errors.QueryError
+	denied := make(map[string]bool)
+	walk(op.SelectionSet, nil, func(field *ast.Field, path []interface{}) {
+		if field.Definition == nil || strings.HasPrefix(field.Name, "__") {
+			return
+		}
+		name := field.ObjectDefinition.Name + "." + field.Name
+		var directives ast.DirectiveList
+		for _, parent := range e.parentTypes(field) {
+			directives = append(directives, parent.Directives...)
+			if definition := parent.Fields.ForName(field.Name); definition != nil {
+				directives = append(directives, definition.Directives...)
+			}
+		}
+		if err := p.denied(name, directives); err != nil {
+			err.Path = path
+			err.Locations = []errors.Location{{Line: field.Position.Line, Column: field.Position.Column}}
+			errs = append(errs, err)
+		}
+		for _, parent := range e.parentTypes(field) {
+			if e.policyDenied(p, parent.Name, field.Name) {
+				denied[parent.Name+"."+field.Name] = true
+			}
+		}
+	})
+	if len(denied) > 0 {
+		errs = append(errs, policyError(p, denied))
+	}
+	return errs
+}
+
+// parentTypes :
+// - Returns the type field is selected on
+// - When it's an interface or a union, every object type implementing it follows,
+// their directives apply too since any of them may be resolved
+func (e *Enforcer) parentTypes(field *ast.Field) []*ast.Definition {
+	parents := []*ast.Definition{field.ObjectDefinition}
+	if field.ObjectDefinition.IsAbstractType() {
+		parents = append(parents, e.schema.GetPossibleTypes(field.ObjectDefinition)...)
+	}
+	return parents
+}
+
+// walk calls fn for each field of selectionSet and its sub selections, with its response path
+func walk(selectionSet ast.SelectionSet, path []interface{}, fn func(field *ast.Field, path []interface{})) {
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			fieldPath := append(append([]interface{}{}, path...), selection.Alias)
+			fn(selection, fieldPath)
+			walk(selection.SelectionSet, fieldPath, fn)
+		case *ast.InlineFragment:
+			walk(selection.SelectionSet, path, fn)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				walk(selection.Definition.SelectionSet, path, fn)
+			}
+		}
+	}
+}
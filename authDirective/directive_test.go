@@ -0,0 +1,197 @@
+package authDirective
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = Directives + `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+	type Query {
+		public: String!
+		me: Account @auth
+		accounts: [Account!]! @hasRole(role: "admin")
+	}
+	type Mutation {
+		deleteAccount(id: ID!): Boolean! @hasScope(scope: "write:accounts")
+	}
+	type Account @auth {
+		name: String!
+		email: String! @hasScope(scope: "read:email")
+	}
+`
+
+type account struct{}
+
+func (a *account) Name() string  { return "bob" }
+func (a *account) Email() string { return "bob@example.com" }
+
+type resolver struct {
+	deleted bool
+}
+
+func (r *resolver) Public() string       { return "public" }
+func (r *resolver) Me() *account         { return &account{} }
+func (r *resolver) Accounts() []*account { return []*account{{}} }
+func (r *resolver) DeleteAccount(args struct{ ID graphql.ID }) bool {
+	r.deleted = true
+	return true
+}
+
+func withUser(roles, scope string) context.Context {
+	raw := map[string]interface{}{"roles": strings.Fields(roles), "scope": scope}
+	return authCommon.UserToContext(context.Background(), &authCommon.User{Subject: "bob", Raw: raw})
+}
+
+func codes(response *graphql.Response) []string {
+	var list []string
+	for _, err := range response.Errors {
+		list = append(list, err.Extensions["code"].(string))
+	}
+	return list
+}
+
+func Test_Enforcer_Exec(t *testing.T) {
+	r := &resolver{}
+	schema := graphql.MustParseSchema(testSchema, r)
+	enforcer, err := NewEnforcer(testSchema)
+	assert.NoError(t, err)
+	anonymous := context.Background()
+
+	response := enforcer.Exec(anonymous, schema, `{ public }`, "", nil)
+	assert.Empty(t, response.Errors)
+
+	response = enforcer.Exec(anonymous, schema, `query Me { profile: me { name } }`, "Me", nil)
+	assert.Equal(t, []string{CodeUnauthenticated, CodeUnauthenticated}, codes(response))
+	assert.Equal(t, []interface{}{"profile"}, response.Errors[0].Path)
+	assert.Nil(t, response.Data)
+
+	response = enforcer.Exec(withUser("", ""), schema, `{ me { name } }`, "", nil)
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"me":{"name":"bob"}}`, string(response.Data))
+
+	// Fragments don't hide fields
+	query := `query { me { ...account } } fragment account on Account { email }`
+	response = enforcer.Exec(withUser("", ""), schema, query, "", nil)
+	assert.Equal(t, []string{CodeForbidden}, codes(response))
+	assert.Equal(t, []interface{}{"me", "email"}, response.Errors[0].Path)
+	response = enforcer.Exec(withUser("", "read:email"), schema, query, "", nil)
+	assert.Empty(t, response.Errors)
+
+	response = enforcer.Exec(withUser("user", ""), schema, `{ accounts { name } }`, "", nil)
+	assert.Equal(t, []string{CodeForbidden}, codes(response))
+	response = enforcer.Exec(withUser("user admin", ""), schema, `{ accounts { name } }`, "", nil)
+	assert.Empty(t, response.Errors)
+
+	// The mutation doesn't run
+	response = enforcer.Exec(withUser("admin", "read:email"), schema, `mutation { deleteAccount(id: "1") }`, "", nil)
+	assert.Equal(t, []string{CodeForbidden}, codes(response))
+	assert.False(t, r.deleted)
+}
+
+func Test_Enforcer_Handler(t *testing.T) {
+	enforcer, _ := NewEnforcer(testSchema)
+	handler := enforcer.Handler(&relay.Handler{Schema: graphql.MustParseSchema(testSchema, &resolver{})})
+
+	serve := func(ctx context.Context, query string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+query+`}`))
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body
+	}
+
+	body := serve(context.Background(), `"{ me { name } }"`)
+	errs := body["errors"].([]interface{})
+	assert.Equal(t, "Query.me requires authentication", errs[0].(map[string]interface{})["message"])
+	assert.Equal(t, map[string]interface{}{"code": CodeUnauthenticated}, errs[0].(map[string]interface{})["extensions"])
+
+	body = serve(withUser("", ""), `"{ me { name } }"`)
+	assert.Nil(t, body["errors"])
+	assert.Equal(t, map[string]interface{}{"me": map[string]interface{}{"name": "bob"}}, body["data"])
+}
+
+func Test_Enforcer_Handler_Transports(t *testing.T) {
+	enforcer, _ := NewEnforcer(testSchema)
+	called := false
+	handler := enforcer.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { called = true }))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		called = false
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Every operation of a batch is checked
+	w := serve(httptest.NewRequest("POST", "/graphql", strings.NewReader(`[{"query":"{ __typename }"},{"query":"{ me { name } }"}]`)))
+	assert.False(t, called)
+	assert.Contains(t, w.Body.String(), "Query.me requires authentication")
+	serve(httptest.NewRequest("POST", "/graphql", strings.NewReader(`[{"query":"{ __typename }"}]`)))
+	assert.True(t, called)
+
+	// GET requests carry the query in the URL
+	w = serve(httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ me { name } }"), nil))
+	assert.False(t, called)
+	assert.Contains(t, w.Body.String(), "Query.me requires authentication")
+	req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ me { name } }"), nil)
+	serve(req.WithContext(withUser("", "")))
+	assert.True(t, called)
+
+	// Bodies that can't be read are rejected
+	for _, body := range []string{"{ me { name } }", `[{"query":1}]`} {
+		w = serve(httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+		assert.False(t, called)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), authUtils.CodeBadRequest)
+	}
+}
+
+var interfaceSchema = Directives + `
+	schema {
+		query: Query
+	}
+	interface Node {
+		id: ID!
+	}
+	type Query {
+		node: Node
+	}
+	type Secret implements Node @auth {
+		id: ID!
+		secret: String @hasRole(role: "admin")
+	}
+`
+
+func Test_Enforcer_Interface(t *testing.T) {
+	enforcer, err := NewEnforcer(interfaceSchema)
+	assert.NoError(t, err)
+
+	// Selecting through the interface must not bypass the directives of Secret
+	errs := enforcer.Check(context.Background(), `{ node { id } }`, "")
+	assert.Len(t, errs, 1)
+	assert.Equal(t, CodeUnauthenticated, errs[0].Extensions["code"])
+	assert.Equal(t, []interface{}{"node", "id"}, errs[0].Path)
+
+	errs = enforcer.Check(withUser("", ""), `{ node { id ... on Secret { secret } } }`, "")
+	assert.Len(t, errs, 1)
+	assert.Equal(t, CodeForbidden, errs[0].Extensions["code"])
+
+	assert.Empty(t, enforcer.Check(withUser("admin", ""), `{ node { id ... on Secret { secret } } }`, ""))
+}
@@ -0,0 +1,94 @@
+package authDirective
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+// Error messages
+var (
+	ErrInvalidRequest = authUtils.NewError("graphql: the request body is not a GraphQL request", authUtils.CodeBadRequest)
+)
+
+// Exec :
+// - Wraps schema.Exec
+// - The query only runs if the user of ctx satisfies the directives of every field, see Check
+func (e *Enforcer) Exec(ctx context.Context, schema *graphql.Schema, query string, operationName string, variables map[string]interface{}) *graphql.Response {
+	if errs := e.Check(ctx, query, operationName); len(errs) > 0 {
+		return &graphql.Response{Errors: errs}
+	}
+	return schema.Exec(ctx, query, operationName, variables)
+}
+
+// operation is a GraphQL request of a client
+type operation struct {
+	Query         string `json:"query"`
+	OperationName string `json:"operationName"`
+}
+
+// readOperations :
+// - Returns the operations of req, whose body is buf
+// - GET requests carry the operation in the query string, others in a JSON object, or a JSON array for batches
+// - Returns ErrInvalidRequest if the body can't be read
+func readOperations(req *http.Request, buf []byte) ([]operation, error) {
+	if req.Method == http.MethodGet {
+		query := req.URL.Query()
+		return []operation{{Query: query.Get("query"), OperationName: query.Get("operationName")}}, nil
+	}
+	if body := bytes.TrimSpace(buf); len(body) > 0 && body[0] == '[' {
+		var batch []operation
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, ErrInvalidRequest
+		}
+		return batch, nil
+	}
+	var op operation
+	if err := json.Unmarshal(buf, &op); err != nil {
+		return nil, ErrInvalidRequest
+	}
+	return []operation{op}, nil
+}
+
+// Handler :
+// - Wraps a GraphQL handler such as relay.Handler
+// - Reads the operations of GET query strings, JSON bodies and batched JSON bodies
+// - Requests whose user doesn't satisfy the directives of every field get a GraphQL error response,
+// a batch is rejected as a whole
+// - Requests it can't read are rejected with ErrInvalidRequest by authUtils.GraphQLFailureHandler
+// - Otherwise, next is called
+func (e *Enforcer) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		var buf []byte
+		if req.Body != nil {
+			buf, _ = ioutil.ReadAll(req.Body)
+		}
+		// Restore the body for next
+		req.Body = ioutil.NopCloser(bytes.NewBuffer(buf))
+
+		operations, err := readOperations(req, buf)
+		if err != nil {
+			ctx := authUtils.WithError(req.Context(), err)
+			authUtils.GraphQLFailureHandler.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		var errs []*errors.QueryError
+		for _, op := range operations {
+			errs = append(errs, e.Check(req.Context(), op.Query, op.OperationName)...)
+		}
+		if len(errs) == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&graphql.Response{Errors: errs})
+	}
+	return http.HandlerFunc(fn)
+}
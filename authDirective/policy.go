@@ -0,0 +1,118 @@
+package authDirective
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+// Rule :
+// - The requirements of a field
+// - Authenticated only requires a user
+// - The user needs one of Roles and all of Scopes
+type Rule struct {
+	Authenticated bool     `json:"authenticated"`
+	Roles         []string `json:"roles"`
+	Scopes        []string `json:"scopes"`
+}
+
+// Policy :
+// - Maps "Type.field" to the Rule of the field, "Type.*" to the Rule of every field of the type
+// - Keeps authorization out of the schema, for teams that can't change it
+type Policy map[string]Rule
+
+// LoadPolicy reads a Policy from a JSON file, such as _config/policy.json
+func LoadPolicy(path string) (Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("directive: invalid policy %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// SetPolicy :
+// - Checks the policy of every operation before it runs, along with the directives
+// - Returns an error if a key doesn't name a type or a field of the schema, so typos don't leave fields open
+func (e *Enforcer) SetPolicy(policy Policy) error {
+	for name := range policy {
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("directive: policy key %q is not Type.field", name)
+		}
+		definition := e.schema.Types[parts[0]]
+		if definition == nil {
+			return fmt.Errorf("directive: policy key %q: unknown type %s", name, parts[0])
+		}
+		if parts[1] != "*" && definition.Fields.ForName(parts[1]) == nil {
+			return fmt.Errorf("directive: policy key %q: unknown field %s", name, parts[1])
+		}
+	}
+	e.policy = policy
+	return nil
+}
+
+// allowed returns true if p satisfies rule
+func (p *principal) allowed(rule Rule) bool {
+	if !rule.Authenticated && len(rule.Roles) == 0 && len(rule.Scopes) == 0 {
+		return true
+	}
+	p.load()
+	if p.user == nil || p.err != nil {
+		return false
+	}
+	if len(rule.Roles) > 0 {
+		found := false
+		for _, role := range rule.Roles {
+			found = found || authUtils.Contains(p.roles, role)
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, scope := range rule.Scopes {
+		if !authUtils.Contains(p.scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// policyDenied returns true if the policy rules of field typeName.fieldName are not satisfied by p
+func (e *Enforcer) policyDenied(p *principal, typeName, fieldName string) bool {
+	for _, name := range []string{typeName + ".*", typeName + "." + fieldName} {
+		if rule, ok := e.policy[name]; ok && !p.allowed(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyError returns the error rejecting an operation that selects the fields denied by the policy
+func policyError(p *principal, denied map[string]bool) *errors.QueryError {
+	fields := make([]string, 0, len(denied))
+	for field := range denied {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	code := CodeForbidden
+	if p.user == nil {
+		code = CodeUnauthenticated
+	}
+	return &errors.QueryError{
+		Message: fmt.Sprintf("operation not allowed, it selects %s", strings.Join(fields, ", ")),
+		Extensions: map[string]interface{}{
+			"code":   code,
+			"fields": fields,
+		},
+	}
+}
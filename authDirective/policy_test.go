@@ -0,0 +1,104 @@
+package authDirective
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// policySchema has no directives, like a schema the team can't change
+var policySchema = `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+	type Query {
+		public: String!
+		me: Account
+		accounts: [Account!]!
+	}
+	type Mutation {
+		deleteAccount(id: ID!): Boolean!
+	}
+	type Account {
+		name: String!
+		email: String!
+	}
+`
+
+func Test_Policy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	ioutil.WriteFile(path, []byte(`{
+		"Query.me": {"authenticated": true},
+		"Query.accounts": {"roles": ["admin", "support"]},
+		"Account.email": {"scopes": ["read:email"]},
+		"Mutation.*": {"roles": ["admin"], "scopes": ["write:accounts"]}
+	}`), 0600)
+	policy, err := LoadPolicy(path)
+	assert.NoError(t, err)
+
+	r := &resolver{}
+	schema := graphql.MustParseSchema(policySchema, r)
+	enforcer, _ := NewEnforcer(policySchema)
+	assert.NoError(t, enforcer.SetPolicy(policy))
+
+	response := enforcer.Exec(context.Background(), schema, `{ public me { name } }`, "", nil)
+	assert.Len(t, response.Errors, 1)
+	assert.Equal(t, CodeUnauthenticated, response.Errors[0].Extensions["code"])
+	assert.Equal(t, []string{"Query.me"}, response.Errors[0].Extensions["fields"])
+	assert.Nil(t, response.Data)
+
+	// Every denied field is listed
+	response = enforcer.Exec(withUser("", ""), schema, `{ me { email } accounts { name } }`, "", nil)
+	assert.Len(t, response.Errors, 1)
+	assert.Equal(t, CodeForbidden, response.Errors[0].Extensions["code"])
+	assert.Equal(t, []string{"Account.email", "Query.accounts"}, response.Errors[0].Extensions["fields"])
+
+	response = enforcer.Exec(withUser("support", "read:email"), schema, `{ me { email } accounts { name } }`, "", nil)
+	assert.Empty(t, response.Errors)
+
+	// The mutation needs the role and the scope, it doesn't run
+	response = enforcer.Exec(withUser("admin", ""), schema, `mutation { deleteAccount(id: "1") }`, "", nil)
+	assert.Equal(t, []string{"Mutation.deleteAccount"}, response.Errors[0].Extensions["fields"])
+	assert.False(t, r.deleted)
+	response = enforcer.Exec(withUser("admin", "write:accounts"), schema, `mutation { deleteAccount(id: "1") }`, "", nil)
+	assert.Empty(t, response.Errors)
+	assert.True(t, r.deleted)
+}
+
+func Test_Policy_Interface(t *testing.T) {
+	enforcer, _ := NewEnforcer(`
+		schema {
+			query: Query
+		}
+		interface Node {
+			id: ID!
+		}
+		type Query {
+			node: Node
+		}
+		type Secret implements Node {
+			id: ID!
+			secret: String
+		}
+	`)
+	assert.NoError(t, enforcer.SetPolicy(Policy{"Secret.*": {Authenticated: true}}))
+
+	// The rules of Secret apply when it's reached through the interface
+	errs := enforcer.Check(context.Background(), `{ node { id } }`, "")
+	assert.Len(t, errs, 1)
+	assert.Equal(t, []string{"Secret.id"}, errs[0].Extensions["fields"])
+
+	assert.Empty(t, enforcer.Check(withUser("", ""), `{ node { id ... on Secret { secret } } }`, ""))
+}
+
+func Test_SetPolicy_UnknownField(t *testing.T) {
+	enforcer, _ := NewEnforcer(policySchema)
+	assert.Error(t, enforcer.SetPolicy(Policy{"Query.acounts": {Authenticated: true}}))
+	assert.Error(t, enforcer.SetPolicy(Policy{"Acount.*": {Authenticated: true}}))
+	assert.Error(t, enforcer.SetPolicy(Policy{"Query": {Authenticated: true}}))
+}
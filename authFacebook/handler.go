@@ -0,0 +1,178 @@
+package authFacebook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnableToGetFacebookUser    = authUtils.NewError("facebook: unable to get Facebook User", authUtils.CodeUpstreamError)
+	ErrCannotValidateFacebookUser = authUtils.NewError("facebook: could not validate Facebook User", authUtils.CodeUpstreamError)
+	ErrInvalidFacebookToken       = authUtils.NewError("facebook: access token is invalid or was issued to another app", authUtils.CodeUnauthenticated)
+)
+
+// DefaultGraphBaseURL is the base URL of the Facebook Graph API
+const DefaultGraphBaseURL = "https://graph.facebook.com"
+
+// DefaultFields are the fields read from the Graph API /me endpoint
+var DefaultFields = []string{"id", "name", "email", "first_name", "last_name", "picture", "locale"}
+
+// User is the Facebook user, as returned by the Graph API /me endpoint
+type User struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Locale    string `json:"locale"`
+	Picture   struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	} `json:"picture"`
+	// Raw holds every requested field
+	Raw map[string]interface{} `json:"-"`
+}
+
+// debugToken is the response of the Graph API /debug_token endpoint
+type debugToken struct {
+	Data struct {
+		AppID   string `json:"app_id"`
+		UserID  string `json:"user_id"`
+		IsValid bool   `json:"is_valid"`
+	} `json:"data"`
+}
+
+// Handler :
+// - Gets the OAuth2 Token from the ctx
+// - Verifies with the debug_token endpoint that the token was issued to our app
+// - Then gets the Facebook User with token
+// - Adds the Facebook User and the normalized authCommon.User to the ctx and the success handler is called
+// - Otherwise, the failure handler is called
+func Handler(config *oauth2.Config, success http.Handler, failure http.Handler) http.Handler {
+	return NewProvider(config).Handler(success, failure)
+}
+
+// Handler is like the package Handler, using the BaseURL and Fields of p
+func (p *Provider) Handler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := authCommon.TokenFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		facebookUser, err := p.fetchUser(ctx, token)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = UserToContext(ctx, facebookUser)
+		ctx = authCommon.UserToContext(ctx, NormalizeUser(facebookUser))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// fetchUser :
+// - Verifies token with the debug_token endpoint
+// - Gets the Facebook User with token
+// - Returns an error if the token is not ours, or the user can't be fetched or is invalid
+func (p *Provider) fetchUser(ctx context.Context, token *oauth2.Token) (*User, error) {
+	httpClient := p.config.Client(ctx, token)
+
+	// The app access token authenticates the debug_token call, not the user token
+	var debug debugToken
+	err := p.get(oauth2.NewClient(ctx, nil), "/debug_token", url.Values{
+		"input_token":  {token.AccessToken},
+		"access_token": {p.config.ClientID + "|" + p.config.ClientSecret},
+	}, &debug)
+	if err != nil {
+		return nil, ErrUnableToGetFacebookUser
+	}
+	if !debug.Data.IsValid || debug.Data.AppID != p.config.ClientID {
+		return nil, ErrInvalidFacebookToken
+	}
+
+	fields := p.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	// The id identifies the user, it's read even when Fields leave it out
+	if !authUtils.Contains(fields, "id") {
+		fields = append([]string{"id"}, fields...)
+	}
+	var raw json.RawMessage
+	err = p.get(httpClient, "/me", url.Values{
+		"fields":          {strings.Join(fields, ",")},
+		"appsecret_proof": {appSecretProof(token.AccessToken, p.config.ClientSecret)},
+	}, &raw)
+	if err != nil {
+		return nil, ErrUnableToGetFacebookUser
+	}
+	var user User
+	if json.Unmarshal(raw, &user) != nil || json.Unmarshal(raw, &user.Raw) != nil {
+		return nil, ErrUnableToGetFacebookUser
+	}
+	if user.ID == "" || user.ID != debug.Data.UserID {
+		return nil, ErrCannotValidateFacebookUser
+	}
+	return &user, nil
+}
+
+// appSecretProof signs the access token with the app secret, as required by
+// apps that enable "Require App Secret" in their settings
+func appSecretProof(accessToken, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(accessToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// get decodes the JSON response of the Graph API endpoint at path into v
+func (p *Provider) get(client *http.Client, path string, query url.Values, v interface{}) error {
+	endpoint := strings.TrimSuffix(p.BaseURL, "/") + path + "?" + query.Encode()
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("facebook: unexpected status " + resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// NormalizeUser maps a Facebook User to an authCommon.User
+// The Graph API doesn't tell whether the email is verified, so EmailVerified is always false
+// and the email can't grant roles, see authz.StaticRoles
+func NormalizeUser(facebookUser *User) *authCommon.User {
+	return &authCommon.User{
+		Provider:      ProviderName,
+		Subject:       facebookUser.ID,
+		Email:         facebookUser.Email,
+		EmailVerified: false,
+		Name:          facebookUser.Name,
+		GivenName:     facebookUser.FirstName,
+		FamilyName:    facebookUser.LastName,
+		Picture:       facebookUser.Picture.Data.URL,
+		Locale:        facebookUser.Locale,
+		Raw:           facebookUser.Raw,
+	}
+}
@@ -0,0 +1,73 @@
+package authFacebook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func newGraphServer(t *testing.T, appID string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug_token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "token", req.URL.Query().Get("input_token"))
+		assert.Equal(t, "app|secret", req.URL.Query().Get("access_token"))
+		fmt.Fprintf(w, `{"data":{"app_id":%q,"user_id":"42","is_valid":true}}`, appID)
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "id,name,email,picture", req.URL.Query().Get("fields"))
+		assert.Equal(t, appSecretProof("token", "secret"), req.URL.Query().Get("appsecret_proof"))
+		fmt.Fprint(w, `{"id":"42","name":"Bob Smith","email":"bob@example.com","picture":{"data":{"url":"https://example.com/bob.png"}}}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func newProvider(baseURL string) *Provider {
+	provider := NewProvider(&oauth2.Config{ClientID: "app", ClientSecret: "secret"})
+	provider.BaseURL = baseURL
+	// Without "id", which is always read
+	provider.Fields = []string{"name", "email", "picture"}
+	return provider
+}
+
+func Test_Handler(t *testing.T) {
+	server := newGraphServer(t, "app")
+	defer server.Close()
+
+	var facebookUser *User
+	var user *authCommon.User
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		facebookUser, _ = UserFromContext(req.Context())
+		user, _ = authCommon.UserFromContext(req.Context())
+	})
+
+	ctx := authCommon.TokenToContext(context.Background(), &oauth2.Token{AccessToken: "token"})
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	newProvider(server.URL).Handler(success, nil).ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	assert.Equal(t, "42", facebookUser.ID)
+	assert.Equal(t, "facebook", user.Provider)
+	assert.Equal(t, "bob@example.com", user.Email)
+	// Facebook doesn't say whether the email is verified
+	assert.False(t, user.EmailVerified)
+	assert.Equal(t, "https://example.com/bob.png", user.Picture)
+	assert.Contains(t, user.Raw, "picture")
+}
+
+func Test_Handler_OtherApp(t *testing.T) {
+	server := newGraphServer(t, "other-app")
+	defer server.Close()
+
+	ctx := authCommon.TokenToContext(context.Background(), &oauth2.Token{AccessToken: "token"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	newProvider(server.URL).Handler(nil, nil).ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrInvalidFacebookToken.Error())
+}
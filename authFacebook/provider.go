@@ -0,0 +1,45 @@
+package authFacebook
+
+import (
+	"context"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the name of the Facebook provider
+const ProviderName = "facebook"
+
+// Provider :
+// - Facebook implementation of authCommon.Provider
+type Provider struct {
+	config *oauth2.Config
+	// BaseURL is the Graph API base URL, override it to test against a local server
+	BaseURL string
+	// Fields are read from the /me endpoint, DefaultFields when empty, "id" is always read
+	Fields []string
+}
+
+// NewProvider returns a Facebook Provider using config, DefaultGraphBaseURL and DefaultFields
+func NewProvider(config *oauth2.Config) *Provider {
+	return &Provider{config: config, BaseURL: DefaultGraphBaseURL, Fields: DefaultFields}
+}
+
+// Name returns ProviderName
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// Config returns the oauth2 config of the provider
+func (p *Provider) Config() *oauth2.Config {
+	return p.config
+}
+
+// User gets the Facebook User with token and normalizes it
+func (p *Provider) User(ctx context.Context, token *oauth2.Token) (*authCommon.User, error) {
+	facebookUser, err := p.fetchUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeUser(facebookUser), nil
+}
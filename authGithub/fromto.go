@@ -0,0 +1,25 @@
+package authGithub
+
+import (
+	"context"
+	"fmt"
+)
+
+type key int
+
+const (
+	UserKey key = iota
+)
+
+func UserToContext(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, UserKey, user)
+}
+
+// UserFromContext returns the user from ctx
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(UserKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("github: Context missing GitHub User")
+	}
+	return user, nil
+}
@@ -0,0 +1,149 @@
+package authGithub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnableToGetGithubUser    = authUtils.NewError("github: unable to get GitHub User", authUtils.CodeUpstreamError)
+	ErrCannotValidateGithubUser = authUtils.NewError("github: could not validate GitHub User", authUtils.CodeUpstreamError)
+)
+
+// DefaultAPIBaseURL is the base URL of the GitHub REST API
+const DefaultAPIBaseURL = "https://api.github.com"
+
+// User is the GitHub user, as returned by the /user endpoint
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	HTMLURL   string `json:"html_url"`
+	// Emails are the addresses returned by the /user/emails endpoint
+	Emails []Email `json:"-"`
+}
+
+// Email is an address of a GitHub user, as returned by the /user/emails endpoint
+type Email struct {
+	Email      string `json:"email"`
+	Primary    bool   `json:"primary"`
+	Verified   bool   `json:"verified"`
+	Visibility string `json:"visibility"`
+}
+
+// Handler :
+// - Gets the OAuth2 Token from the ctx
+// - Then gets the GitHub User and its emails with token
+// - Adds the GitHub User and the normalized authCommon.User to the ctx and the success handler is called
+// - Otherwise, the failure handler is called
+// - The config needs the "read:user" and "user:email" scopes
+func Handler(config *oauth2.Config, success http.Handler, failure http.Handler) http.Handler {
+	return NewProvider(config).Handler(success, failure)
+}
+
+// Handler is like the package Handler, using the BaseURL of p
+func (p *Provider) Handler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := authCommon.TokenFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		githubUser, err := p.fetchUser(ctx, token)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = UserToContext(ctx, githubUser)
+		ctx = authCommon.UserToContext(ctx, NormalizeUser(githubUser))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// fetchUser :
+// - Gets the GitHub User and its emails with token
+// - Returns an error if they can't be fetched or are invalid
+func (p *Provider) fetchUser(ctx context.Context, token *oauth2.Token) (*User, error) {
+	httpClient := p.config.Client(ctx, token)
+
+	var user User
+	if err := p.get(httpClient, "/user", &user); err != nil {
+		return nil, ErrUnableToGetGithubUser
+	}
+	if user.ID == 0 {
+		return nil, ErrCannotValidateGithubUser
+	}
+	if err := p.get(httpClient, "/user/emails", &user.Emails); err != nil {
+		return nil, ErrUnableToGetGithubUser
+	}
+	return &user, nil
+}
+
+// get decodes the JSON response of the API endpoint at path into v
+func (p *Provider) get(client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.BaseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("github: unexpected status " + resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// primaryEmail returns the primary verified email of the user, if any
+func primaryEmail(user *User) (string, bool) {
+	for _, email := range user.Emails {
+		if email.Primary && email.Verified {
+			return email.Email, true
+		}
+	}
+	return "", false
+}
+
+// NormalizeUser maps a GitHub User to an authCommon.User
+func NormalizeUser(githubUser *User) *authCommon.User {
+	user := &authCommon.User{
+		Provider: ProviderName,
+		Subject:  strconv.FormatInt(githubUser.ID, 10),
+		Name:     githubUser.Name,
+		Picture:  githubUser.AvatarURL,
+		Raw: map[string]interface{}{
+			"id":         githubUser.ID,
+			"login":      githubUser.Login,
+			"name":       githubUser.Name,
+			"email":      githubUser.Email,
+			"avatar_url": githubUser.AvatarURL,
+			"html_url":   githubUser.HTMLURL,
+		},
+	}
+	user.Email, user.EmailVerified = primaryEmail(githubUser)
+	if user.Name == "" {
+		user.Name = githubUser.Login
+	}
+	return user
+}
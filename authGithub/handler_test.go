@@ -0,0 +1,89 @@
+package authGithub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func newAPIServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"id":42,"login":"bob","name":"","email":"public@example.com","avatar_url":"https://example.com/bob.png"}`)
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `[
+			{"email":"primary-unverified@example.com","primary":true,"verified":false},
+			{"email":"other@example.com","primary":false,"verified":true}
+		]`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func Test_Handler(t *testing.T) {
+	server := newAPIServer(t)
+	defer server.Close()
+
+	provider := NewProvider(&oauth2.Config{})
+	provider.BaseURL = server.URL
+
+	var githubUser *User
+	var user *authCommon.User
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		githubUser, _ = UserFromContext(req.Context())
+		user, _ = authCommon.UserFromContext(req.Context())
+	})
+
+	ctx := authCommon.TokenToContext(context.Background(), &oauth2.Token{AccessToken: "token"})
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	provider.Handler(success, nil).ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	assert.Equal(t, int64(42), githubUser.ID)
+	assert.Len(t, githubUser.Emails, 2)
+	assert.Equal(t, "github", user.Provider)
+	assert.Equal(t, "42", user.Subject)
+	assert.Equal(t, "bob", user.Name)
+	// Neither the unverified primary email nor the public one is used
+	assert.Equal(t, "", user.Email)
+	assert.False(t, user.EmailVerified)
+}
+
+func Test_NormalizeUser(t *testing.T) {
+	user := NormalizeUser(&User{
+		ID:    42,
+		Login: "bob",
+		Name:  "Bob",
+		Emails: []Email{
+			{Email: "other@example.com", Verified: true},
+			{Email: "bob@example.com", Primary: true, Verified: true},
+		},
+	})
+	assert.Equal(t, "bob@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "Bob", user.Name)
+}
+
+func Test_Handler_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "Bad credentials", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&oauth2.Config{})
+	provider.BaseURL = server.URL
+
+	ctx := authCommon.TokenToContext(context.Background(), &oauth2.Token{AccessToken: "token"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	provider.Handler(nil, nil).ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrUnableToGetGithubUser.Error())
+}
@@ -0,0 +1,43 @@
+package authGithub
+
+import (
+	"context"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the name of the GitHub provider
+const ProviderName = "github"
+
+// Provider :
+// - GitHub implementation of authCommon.Provider
+type Provider struct {
+	config *oauth2.Config
+	// BaseURL is the GitHub API base URL, override it to test against a local server
+	BaseURL string
+}
+
+// NewProvider returns a GitHub Provider using config and DefaultAPIBaseURL
+func NewProvider(config *oauth2.Config) *Provider {
+	return &Provider{config: config, BaseURL: DefaultAPIBaseURL}
+}
+
+// Name returns ProviderName
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// Config returns the oauth2 config of the provider
+func (p *Provider) Config() *oauth2.Config {
+	return p.config
+}
+
+// User gets the GitHub User with token and normalizes it
+func (p *Provider) User(ctx context.Context, token *oauth2.Token) (*authCommon.User, error) {
+	githubUser, err := p.fetchUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeUser(githubUser), nil
+}
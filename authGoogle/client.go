@@ -0,0 +1,143 @@
+package authGoogle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authToken"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoGoogleToken = authUtils.NewError("google: no Google token stored for the user", authUtils.CodeConsentRequired)
+	ErrTokenRevoked  = authUtils.NewError("google: Google token has been revoked", authUtils.CodeConsentRequired)
+	ErrMissingScope  = authUtils.NewError("google: Google token lacks a required scope", authUtils.CodeConsentRequired)
+)
+
+// ConsentError :
+// - Returned when the user has to sign in with Google again
+// - Err is ErrNoGoogleToken, ErrTokenRevoked or ErrMissingScope, use errors.Is
+// - MissingScopes are the required scopes the user hasn't granted, ask for them on the new auth URL
+type ConsentError struct {
+	Err           error
+	MissingScopes []string
+}
+
+func (e *ConsentError) Error() string {
+	if len(e.MissingScopes) > 0 {
+		return e.Err.Error() + ": " + strings.Join(e.MissingScopes, " ")
+	}
+	return e.Err.Error()
+}
+
+func (e *ConsentError) Unwrap() error {
+	return e.Err
+}
+
+// consentErr turns the errors of a token source that require a new consent into a *ConsentError
+func consentErr(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	switch {
+	case errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant":
+		return &ConsentError{Err: ErrTokenRevoked}
+	case errors.Is(err, authToken.ErrNoRefreshToken), errors.Is(err, authToken.ErrTokenNotFound):
+		return &ConsentError{Err: ErrNoGoogleToken}
+	}
+	return err
+}
+
+// consentSource reports the refresh errors of the client requests as *ConsentError too
+type consentSource struct {
+	source oauth2.TokenSource
+}
+
+func (s consentSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, consentErr(err)
+	}
+	return token, nil
+}
+
+// consentTransport reports the requests rejected because the access token was revoked as *ConsentError
+// - Google answers 401 with error="invalid_token" in the WWW-Authenticate header
+type consentTransport struct {
+	base http.RoundTripper
+}
+
+func (t consentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && strings.Contains(resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`) {
+		resp.Body.Close()
+		return nil, &ConsentError{Err: ErrTokenRevoked}
+	}
+	return resp, nil
+}
+
+// missingScopes returns the scopes not granted to token
+// - Tokens that don't list their scopes are assumed to have none of them
+func missingScopes(token *oauth2.Token, scopes []string) []string {
+	granted, _ := token.Extra("scope").(string)
+	var missing []string
+	for _, scope := range scopes {
+		if !authUtils.Contains(strings.Fields(granted), scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// ClientMiddleware :
+// - Adds store to the ctx of each request, so resolvers can call ClientFromContext
+// - Save the tokens in store after the callback with authToken.Handler
+// - The config of p refreshes the stored tokens
+func (p *Provider) ClientMiddleware(store authToken.TokenStore, next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := tokenClientToContext(req.Context(), &tokenClient{config: p.config, store: store})
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// ClientFromContext :
+// - Returns an *http.Client calling Google APIs (Calendar, Drive...) as the authCommon.User of ctx
+// - It uses the stored token of the user, refreshed and saved again when it expires
+// - scopes are required scopes, like calendar.CalendarReadonlyScope
+// - Returns a *ConsentError when the user has no token, it was revoked or lacks one of scopes
+// - The requests of the client return it too (wrapped in a *url.Error) if the token is revoked later on,
+// whether the refresh fails or Google rejects the access token
+func ClientFromContext(ctx context.Context, scopes ...string) (*http.Client, error) {
+	client, err := tokenClientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := authCommon.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Provider != ProviderName {
+		return nil, &ConsentError{Err: ErrNoGoogleToken}
+	}
+
+	source, err := authToken.TokenSource(ctx, client.store, client.config, user.ID(), ProviderName)
+	if err != nil {
+		return nil, consentErr(err)
+	}
+	token, err := source.Token()
+	if err != nil {
+		return nil, consentErr(err)
+	}
+	if missing := missingScopes(token, scopes); len(missing) > 0 {
+		return nil, &ConsentError{Err: ErrMissingScope, MissingScopes: missing}
+	}
+	httpClient := oauth2.NewClient(ctx, consentSource{source})
+	httpClient.Transport = consentTransport{base: httpClient.Transport}
+	return httpClient, nil
+}
@@ -0,0 +1,90 @@
+package authGoogle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authToken"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_ClientFromContext(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	}))
+	defer tokenSrv.Close()
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer apiSrv.Close()
+
+	store := authToken.NewMemoryStore(authUtils.NewKeyring([]byte("secret")))
+	provider := NewProvider(&oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenSrv.URL}})
+	bob := &authCommon.User{Provider: "google", Subject: "bob"}
+
+	// Returns the ctx resolvers get
+	resolverCtx := func(user *authCommon.User) context.Context {
+		var ctx context.Context
+		next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { ctx = req.Context() })
+		req := httptest.NewRequest("POST", "/graphql", nil)
+		req = req.WithContext(authCommon.UserToContext(req.Context(), user))
+		provider.ClientMiddleware(store, next).ServeHTTP(httptest.NewRecorder(), req)
+		return ctx
+	}
+
+	// No token yet
+	_, err := ClientFromContext(resolverCtx(bob))
+	assert.True(t, errors.Is(err, ErrNoGoogleToken))
+
+	// A valid token
+	token := (&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}).
+		WithExtra(map[string]interface{}{"scope": "openid https://www.googleapis.com/auth/calendar.readonly"})
+	store.Save(context.Background(), "google:bob", "google", token)
+	client, err := ClientFromContext(resolverCtx(bob), "https://www.googleapis.com/auth/calendar.readonly")
+	assert.NoError(t, err)
+	resp, err := client.Get(apiSrv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// An access token revoked before it expires
+	revokedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="https://accounts.google.com/", error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer revokedSrv.Close()
+	_, err = client.Get(revokedSrv.URL)
+	assert.True(t, errors.Is(err, ErrTokenRevoked))
+
+	// Missing scope
+	_, err = ClientFromContext(resolverCtx(bob), "https://www.googleapis.com/auth/drive")
+	var consentErr *ConsentError
+	assert.True(t, errors.As(err, &consentErr))
+	assert.Equal(t, ErrMissingScope, consentErr.Err)
+	assert.Equal(t, []string{"https://www.googleapis.com/auth/drive"}, consentErr.MissingScopes)
+
+	// Tokens saved without their scopes don't grant any
+	store.Save(context.Background(), "google:bob", "google", &oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(time.Hour)})
+	_, err = ClientFromContext(resolverCtx(bob), "https://www.googleapis.com/auth/calendar.readonly")
+	assert.True(t, errors.As(err, &consentErr))
+	assert.Equal(t, []string{"https://www.googleapis.com/auth/calendar.readonly"}, consentErr.MissingScopes)
+	_, err = ClientFromContext(resolverCtx(bob))
+	assert.NoError(t, err)
+
+	// Revoked refresh token
+	store.Save(context.Background(), "google:bob", "google", &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)})
+	_, err = ClientFromContext(resolverCtx(bob))
+	assert.True(t, errors.Is(err, ErrTokenRevoked))
+
+	// Not a Google user
+	_, err = ClientFromContext(resolverCtx(&authCommon.User{Provider: "github", Subject: "bob"}))
+	assert.True(t, errors.Is(err, ErrNoGoogleToken))
+}
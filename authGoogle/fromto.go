@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/astenmies/graphql-go-auth/authToken"
+	"golang.org/x/oauth2"
 	google "google.golang.org/api/oauth2/v2"
 )
 
@@ -11,6 +13,7 @@ type key int
 
 const (
 	UserKey key = iota
+	ClientKey
 )
 
 func UserToContext(ctx context.Context, user *google.Userinfoplus) context.Context {
@@ -25,3 +28,21 @@ func UserFromContext(ctx context.Context) (*google.Userinfoplus, error) {
 	}
 	return user, nil
 }
+
+// tokenClient is what ClientFromContext needs to build a client, see Provider.ClientMiddleware
+type tokenClient struct {
+	config *oauth2.Config
+	store  authToken.TokenStore
+}
+
+func tokenClientToContext(ctx context.Context, client *tokenClient) context.Context {
+	return context.WithValue(ctx, ClientKey, client)
+}
+
+func tokenClientFromContext(ctx context.Context) (*tokenClient, error) {
+	client, ok := ctx.Value(ClientKey).(*tokenClient)
+	if !ok {
+		return nil, fmt.Errorf("google: Context missing Google token store, see Provider.ClientMiddleware")
+	}
+	return client, nil
+}
@@ -1,56 +1,44 @@
 package authGoogle
 
 import (
-	"errors"
+	"context"
 	"net/http"
 
-	"github.com/astenmies/graphql-go-auth/authCommon"
 	"github.com/astenmies/graphql-go-auth/authUtils"
 	"golang.org/x/oauth2"
 	google "google.golang.org/api/oauth2/v2"
 )
 
 var (
-	ErrUnableToGetGoogleUser    = errors.New("google: unable to get Google User")
-	ErrCannotValidateGoogleUser = errors.New("google: could not validate Google User")
+	ErrUnableToGetGoogleUser    = authUtils.NewError("google: unable to get Google User", authUtils.CodeUpstreamError)
+	ErrCannotValidateGoogleUser = authUtils.NewError("google: could not validate Google User", authUtils.CodeUpstreamError)
 )
 
 // GoogleHandler :
 // - Gets the OAuth2 Token from the ctx
 // - Then gets Google Userinfoplus with token
-// - Adds user info to the ctx and the success handler is called
+// - Adds user info and the normalized authCommon.User to the ctx and the success handler is called
 // - Otherwise, the failure handler is called
+// - Use NewProvider and Provider.Handler to verify the id_token instead of calling userinfo
 func Handler(config *oauth2.Config, success http.Handler, failure http.Handler) http.Handler {
-	if failure == nil {
-		failure = authUtils.DefaultFailureHandler
-	}
-	fn := func(w http.ResponseWriter, req *http.Request) {
-		ctx := req.Context()
-		token, err := authCommon.TokenFromContext(ctx)
-		if err != nil {
-			ctx = authUtils.WithError(ctx, err)
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
-		httpClient := config.Client(ctx, token)
-		googleService, err := google.New(httpClient)
-		if err != nil {
-			ctx = authUtils.WithError(ctx, err)
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
-		userInfoPlus, err := googleService.Userinfo.Get().Do()
-		err = validateResponse(userInfoPlus, err)
-		if err != nil {
-			ctx = authUtils.WithError(ctx, err)
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
+	return NewProvider(config).Handler(success, failure)
+}
 
-		ctx = UserToContext(ctx, userInfoPlus)
-		success.ServeHTTP(w, req.WithContext(ctx))
+// fetchUser :
+// - Gets Google Userinfoplus with token
+// - Returns an error if it can't be fetched or is invalid
+func fetchUser(ctx context.Context, config *oauth2.Config, token *oauth2.Token) (*google.Userinfoplus, error) {
+	httpClient := config.Client(ctx, token)
+	googleService, err := google.New(httpClient)
+	if err != nil {
+		return nil, err
+	}
+	userInfoPlus, err := googleService.Userinfo.Get().Do()
+	err = validateResponse(userInfoPlus, err)
+	if err != nil {
+		return nil, err
 	}
-	return http.HandlerFunc(fn)
+	return userInfoPlus, nil
 }
 
 // validateResponse :
@@ -0,0 +1,197 @@
+package authGoogle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authOIDC"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+	google "google.golang.org/api/oauth2/v2"
+)
+
+// ProviderName is the name of the Google provider
+const ProviderName = "google"
+
+const (
+	// Issuer is the iss claim of Google id_tokens
+	Issuer = "https://accounts.google.com"
+	// DefaultJWKSURL serves the keys that sign Google id_tokens
+	DefaultJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// Provider :
+// - Google implementation of authCommon.Provider
+// - By default, users are fetched from the userinfo endpoint
+// - With VerifyIDToken, users are built from the id_token of the token response instead
+type Provider struct {
+	config *oauth2.Config
+	// VerifyIDToken verifies the id_token locally and skips the userinfo call.
+	// The config needs the "openid" scope.
+	VerifyIDToken bool
+	// JWKSURL serves the keys that sign id_tokens, override it to test against a local server
+	JWKSURL string
+	// ExtraFields are userinfo fields added to User.Raw. When set, the userinfo
+	// endpoint is still called after the id_token is verified.
+	ExtraFields []string
+	// RevocationURL revokes tokens on logout, override it to test against a local server
+	RevocationURL string
+
+	verifierOnce sync.Once
+	verifier     *authOIDC.Verifier
+}
+
+// NewProvider returns a Google Provider using config
+func NewProvider(config *oauth2.Config) *Provider {
+	return &Provider{config: config, JWKSURL: DefaultJWKSURL, RevocationURL: DefaultRevocationURL}
+}
+
+// Name returns ProviderName
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// Config returns the oauth2 config of the provider
+func (p *Provider) Config() *oauth2.Config {
+	return p.config
+}
+
+// AuthCodeOptions sends the nonce of the flow in ctx on the auth URL when the id_token is verified,
+// see authCommon.ProviderLoginHandler
+func (p *Provider) AuthCodeOptions(ctx context.Context) []oauth2.AuthCodeOption {
+	if !p.VerifyIDToken {
+		return nil
+	}
+	return authCommon.NonceOptions(ctx)
+}
+
+// User gets the Google user with token and normalizes it
+func (p *Provider) User(ctx context.Context, token *oauth2.Token) (*authCommon.User, error) {
+	_, user, err := p.fetch(ctx, token)
+	return user, err
+}
+
+// Handler is like the package Handler, with the options of p
+func (p *Provider) Handler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := authCommon.TokenFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		userInfoPlus, user, err := p.fetch(ctx, token)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = UserToContext(ctx, userInfoPlus)
+		ctx = authCommon.UserToContext(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// fetch :
+// - Gets Google Userinfoplus from the userinfo endpoint
+// - Or from the verified id_token claims when VerifyIDToken is set
+// - Returns it with the normalized user
+func (p *Provider) fetch(ctx context.Context, token *oauth2.Token) (*google.Userinfoplus, *authCommon.User, error) {
+	if !p.VerifyIDToken {
+		userInfoPlus, err := fetchUser(ctx, p.config, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		return userInfoPlus, NormalizeUser(userInfoPlus), nil
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, nil, authOIDC.ErrMissingIDToken
+	}
+	nonce, err := authCommon.NonceFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := p.idTokenVerifier().Verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	userInfoPlus := userFromClaims(claims)
+	user := NormalizeUser(userInfoPlus)
+	user.Raw = claims
+
+	if len(p.ExtraFields) > 0 {
+		info, err := fetchUser(ctx, p.config, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.Id != userInfoPlus.Id {
+			return nil, nil, ErrCannotValidateGoogleUser
+		}
+		var fields map[string]interface{}
+		if b, err := json.Marshal(info); err == nil {
+			json.Unmarshal(b, &fields)
+		}
+		for _, field := range p.ExtraFields {
+			if value, ok := fields[field]; ok {
+				user.Raw[field] = value
+			}
+		}
+	}
+	return userInfoPlus, user, nil
+}
+
+func (p *Provider) idTokenVerifier() *authOIDC.Verifier {
+	p.verifierOnce.Do(func() {
+		p.verifier = authOIDC.NewVerifier(Issuer, p.config.ClientID, p.JWKSURL)
+		p.verifier.AlternateIssuers = []string{"accounts.google.com"}
+	})
+	return p.verifier
+}
+
+// userFromClaims maps verified id_token claims to Google Userinfoplus
+func userFromClaims(claims authOIDC.Claims) *google.Userinfoplus {
+	verified := claims.Bool("email_verified")
+	return &google.Userinfoplus{
+		Id:            claims.String("sub"),
+		Email:         claims.String("email"),
+		VerifiedEmail: &verified,
+		Name:          claims.String("name"),
+		GivenName:     claims.String("given_name"),
+		FamilyName:    claims.String("family_name"),
+		Picture:       claims.String("picture"),
+		Locale:        claims.String("locale"),
+		Hd:            claims.String("hd"),
+	}
+}
+
+// NormalizeUser maps Google Userinfoplus to an authCommon.User
+func NormalizeUser(userInfoPlus *google.Userinfoplus) *authCommon.User {
+	user := &authCommon.User{
+		Provider:   ProviderName,
+		Subject:    userInfoPlus.Id,
+		Email:      userInfoPlus.Email,
+		Name:       userInfoPlus.Name,
+		GivenName:  userInfoPlus.GivenName,
+		FamilyName: userInfoPlus.FamilyName,
+		Picture:    userInfoPlus.Picture,
+		Locale:     userInfoPlus.Locale,
+	}
+	if userInfoPlus.VerifiedEmail != nil {
+		user.EmailVerified = *userInfoPlus.VerifiedEmail
+	}
+	if b, err := json.Marshal(userInfoPlus); err == nil {
+		json.Unmarshal(b, &user.Raw)
+	}
+	return user
+}
@@ -0,0 +1,109 @@
+package authGoogle
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authOIDC"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	google "google.golang.org/api/oauth2/v2"
+)
+
+func Test_NormalizeUser(t *testing.T) {
+	verified := true
+	user := NormalizeUser(&google.Userinfoplus{
+		Id:            "42",
+		Email:         "bob@example.com",
+		VerifiedEmail: &verified,
+		Name:          "Bob Smith",
+		GivenName:     "Bob",
+		FamilyName:    "Smith",
+		Picture:       "https://example.com/bob.png",
+		Locale:        "en",
+		Hd:            "example.com",
+	})
+
+	assert.Equal(t, "google", user.Provider)
+	assert.Equal(t, "42", user.Subject)
+	assert.Equal(t, "bob@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "Bob Smith", user.Name)
+	assert.Equal(t, "Bob", user.GivenName)
+	assert.Equal(t, "Smith", user.FamilyName)
+	assert.Equal(t, "https://example.com/bob.png", user.Picture)
+	assert.Equal(t, "en", user.Locale)
+	assert.Equal(t, "example.com", user.Raw["hd"])
+}
+
+func Test_Provider_VerifyIDToken(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(authOIDC.JSONWebKeySet{Keys: []authOIDC.JSONWebKey{{
+			Kty: "RSA",
+			Kid: "key",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            "accounts.google.com",
+		"aud":            "client",
+		"sub":            "42",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          authCommon.NonceForVerifier("verifier"),
+		"email":          "bob@example.com",
+		"email_verified": true,
+		"given_name":     "Bob",
+		"hd":             "example.com",
+	})
+	idToken.Header["kid"] = "key"
+	rawIDToken, _ := idToken.SignedString(key)
+
+	provider := NewProvider(&oauth2.Config{ClientID: "client"})
+	provider.VerifyIDToken = true
+	provider.JWKSURL = jwks.URL
+
+	var userInfoPlus *google.Userinfoplus
+	var user *authCommon.User
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		userInfoPlus, _ = UserFromContext(req.Context())
+		user, _ = authCommon.UserFromContext(req.Context())
+	})
+
+	// No userinfo call: the token is only valid for the id_token
+	token := (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]interface{}{"id_token": rawIDToken})
+	ctx := authCommon.TokenToContext(context.Background(), token)
+	ctx = authCommon.VerifierToContext(ctx, "verifier")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	provider.Handler(success, nil).ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "42", userInfoPlus.Id)
+	assert.Equal(t, "example.com", userInfoPlus.Hd)
+	assert.Equal(t, "42", user.Subject)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "Bob", user.GivenName)
+	assert.Equal(t, "example.com", user.Raw["hd"])
+
+	// Another nonce is rejected
+	ctx = authCommon.VerifierToContext(ctx, "other")
+	w = httptest.NewRecorder()
+	provider.Handler(success, nil).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), authOIDC.ErrNonceMismatch.Error())
+}
@@ -0,0 +1,52 @@
+package authGoogle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+// DefaultRevocationURL is the Google endpoint that revokes tokens
+const DefaultRevocationURL = "https://oauth2.googleapis.com/revoke"
+
+var (
+	ErrUnableToRevokeGoogleToken = authUtils.NewError("google: unable to revoke Google token", authUtils.CodeUpstreamError)
+)
+
+// Revoke :
+// - Revokes token at RevocationURL, implements authCommon.Revoker
+// - The refresh token is revoked when there is one, which also revokes its access tokens
+// - A token Google doesn't know anymore (already revoked or expired) is not an error
+func (p *Provider) Revoke(ctx context.Context, token *oauth2.Token) error {
+	value := token.RefreshToken
+	if value == "" {
+		value = token.AccessToken
+	}
+	form := url.Values{"token": {value}}
+	req, err := http.NewRequest("POST", p.RevocationURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := oauth2.NewClient(ctx, nil).Do(req.WithContext(ctx))
+	if err != nil {
+		return ErrUnableToRevokeGoogleToken
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode == http.StatusBadRequest && body.Error == "invalid_token" {
+		return nil
+	}
+	return ErrUnableToRevokeGoogleToken
+}
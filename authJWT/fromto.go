@@ -0,0 +1,41 @@
+package authJWT
+
+import (
+	"context"
+	"fmt"
+)
+
+type key int
+
+const (
+	TokenKey  key = iota
+	ClaimsKey key = iota
+)
+
+// TokenToContext adds the issued access token to ctx
+func TokenToContext(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, TokenKey, token)
+}
+
+// ClaimsToContext adds the claims of a verified access token to ctx
+func ClaimsToContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ClaimsKey, claims)
+}
+
+// TokenFromContext returns the issued access token from ctx
+func TokenFromContext(ctx context.Context) (string, error) {
+	token, ok := ctx.Value(TokenKey).(string)
+	if !ok {
+		return "", fmt.Errorf("jwt: Context missing access token")
+	}
+	return token, nil
+}
+
+// ClaimsFromContext returns the claims of the verified access token from ctx
+func ClaimsFromContext(ctx context.Context) (Claims, error) {
+	claims, ok := ctx.Value(ClaimsKey).(Claims)
+	if !ok {
+		return nil, fmt.Errorf("jwt: Context missing access token claims")
+	}
+	return claims, nil
+}
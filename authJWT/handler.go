@@ -0,0 +1,103 @@
+package authJWT
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error messages
+var (
+	ErrMissingBearer = authUtils.NewError("jwt: Authorization header is not a bearer token", authUtils.CodeUnauthenticated)
+)
+
+// DefaultHeader is the response header that carries the access token issued by Handler
+const DefaultHeader = "X-Access-Token"
+
+// Handler :
+// - Gets the authCommon.User from the ctx, as set by a provider handler
+// - Issues an access token for it
+// - Sets it in the header response header (DefaultHeader when empty)
+// - Adds it to the ctx and the success handler is called, which may return it in a GraphQL result
+// - Otherwise, the failure handler is called
+func (i *Issuer) Handler(header string, success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	if header == "" {
+		header = DefaultHeader
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := authCommon.UserFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := i.Issue(ctx, user)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		w.Header().Set(header, token)
+		ctx = TokenToContext(ctx, token)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// bearerToken returns the token of the "Authorization: Bearer" header of req
+func bearerToken(req *http.Request) (string, bool, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return "", false, nil
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return "", true, ErrMissingBearer
+	}
+	return strings.TrimSpace(parts[1]), true, nil
+}
+
+// BearerMiddleware :
+// - Verifies the access token of the "Authorization: Bearer" header
+// - Adds its claims and the authCommon.User it was issued for to the ctx,
+// so resolvers see the same principal as with a session cookie
+// - Requests without Authorization header continue without them
+// - Otherwise, the failure handler is called
+func (i *Issuer) BearerMiddleware(next http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, ok, err := bearerToken(req)
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := i.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = ClaimsToContext(ctx, claims)
+		ctx = authCommon.UserToContext(ctx, UserFromClaims(claims))
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
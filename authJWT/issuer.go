@@ -0,0 +1,236 @@
+package authJWT
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Error messages
+var (
+	ErrInvalidToken    = authUtils.NewError("jwt: invalid access token", authUtils.CodeUnauthenticated)
+	ErrNoSigningMethod = authUtils.NewError("jwt: the issuer has no signing method nor key set", authUtils.CodeInternalError)
+)
+
+// DefaultTTL is the lifetime of the access tokens of an Issuer with no TTL
+const DefaultTTL = time.Hour
+
+// Claims are the claims of an access token
+type Claims map[string]interface{}
+
+// String returns the string claim name, or ""
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Bool returns the boolean claim name, or false
+func (c Claims) Bool(name string) bool {
+	b, _ := c[name].(bool)
+	return b
+}
+
+// Issuer :
+// - Mints signed access tokens (JWT) for authenticated users
+// - Verifies them, see BearerMiddleware
+type Issuer struct {
+	// Method is jwt.SigningMethodHS256, jwt.SigningMethodRS256 or jwt.SigningMethodEdDSA
+	Method jwt.SigningMethod
+	// SigningKey is a []byte secret (HS256), a *rsa.PrivateKey (RS256) or an ed25519.PrivateKey (EdDSA)
+	SigningKey interface{}
+	// Keys, when set, replaces Method and SigningKey with rotating keys identified by kid
+	Keys *KeySet
+	// Issuer is the iss claim
+	Issuer string
+	// Audience is the aud claim
+	Audience []string
+	// TTL is the lifetime of the tokens, DefaultTTL when zero
+	TTL time.Duration
+	// Claims are added to every token
+	Claims map[string]interface{}
+	// ClaimsFunc returns claims added to the token of user, like roles
+	ClaimsFunc func(ctx context.Context, user *authCommon.User) (map[string]interface{}, error)
+
+	now func() time.Time
+}
+
+// NewHS256Issuer returns an Issuer signing with the HMAC secret
+func NewHS256Issuer(secret []byte, issuer string, audience ...string) *Issuer {
+	return &Issuer{Method: jwt.SigningMethodHS256, SigningKey: secret, Issuer: issuer, Audience: audience}
+}
+
+// NewRS256Issuer returns an Issuer signing with the RSA key
+func NewRS256Issuer(key *rsa.PrivateKey, issuer string, audience ...string) *Issuer {
+	return &Issuer{Method: jwt.SigningMethodRS256, SigningKey: key, Issuer: issuer, Audience: audience}
+}
+
+// NewEdDSAIssuer returns an Issuer signing with the Ed25519 key
+func NewEdDSAIssuer(key ed25519.PrivateKey, issuer string, audience ...string) *Issuer {
+	return &Issuer{Method: jwt.SigningMethodEdDSA, SigningKey: key, Issuer: issuer, Audience: audience}
+}
+
+// NewKeySetIssuer returns an Issuer signing with the current key of keys
+func NewKeySetIssuer(keys *KeySet, issuer string, audience ...string) *Issuer {
+	return &Issuer{Keys: keys, Issuer: issuer, Audience: audience}
+}
+
+// clock returns the time of the Issuer, the one of Keys when set
+func (i *Issuer) clock() time.Time {
+	if i.now != nil {
+		return i.now()
+	}
+	if i.Keys != nil {
+		return i.Keys.clock()
+	}
+	return time.Now()
+}
+
+// verifyKey returns the key that verifies the signatures of SigningKey
+func (i *Issuer) verifyKey() interface{} {
+	if signer, ok := i.SigningKey.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return i.SigningKey
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Issue :
+// - Returns a signed access token for user
+// - Holds the iss, aud, sub, iat, nbf, exp and jti claims, the user profile and the custom claims
+// - Custom claims can't override the registered ones
+func (i *Issuer) Issue(ctx context.Context, user *authCommon.User) (string, error) {
+	if i.Keys == nil && i.Method == nil {
+		return "", ErrNoSigningMethod
+	}
+	claims := jwt.MapClaims{}
+	for name, value := range i.Claims {
+		claims[name] = value
+	}
+	if i.ClaimsFunc != nil {
+		custom, err := i.ClaimsFunc(ctx, user)
+		if err != nil {
+			return "", err
+		}
+		for name, value := range custom {
+			claims[name] = value
+		}
+	}
+
+	ttl := i.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	now := i.clock()
+	profile := map[string]interface{}{
+		"provider":       user.Provider,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+		"given_name":     user.GivenName,
+		"family_name":    user.FamilyName,
+		"picture":        user.Picture,
+		"locale":         user.Locale,
+	}
+	for name, value := range profile {
+		if value != "" && value != false {
+			claims[name] = value
+		}
+	}
+	claims["iss"] = i.Issuer
+	claims["sub"] = user.Subject
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["jti"] = newTokenID()
+	if len(i.Audience) > 0 {
+		claims["aud"] = i.Audience
+	}
+
+	if i.Keys != nil {
+		key, err := i.Keys.current(ttl)
+		if err != nil {
+			return "", err
+		}
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		return token.SignedString(key.Key)
+	}
+	return jwt.NewWithClaims(i.Method, claims).SignedString(i.SigningKey)
+}
+
+// keyFunc returns the key that verifies token
+func (i *Issuer) keyFunc(token *jwt.Token) (interface{}, error) {
+	if i.Keys == nil {
+		return i.verifyKey(), nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, err := i.Keys.signingKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.Method.Alg() != token.Method.Alg() {
+		return nil, ErrUnknownKey
+	}
+	return key.Key.Public(), nil
+}
+
+// Verify :
+// - Verifies the signature, iss, aud, exp and nbf of rawToken
+// - With Keys, the signature is checked with the current or a retained key, picked by kid
+// - Returns its claims, or an error wrapping ErrInvalidToken
+// - Returns ErrNoSigningMethod when the Issuer has neither Method nor Keys
+func (i *Issuer) Verify(rawToken string) (Claims, error) {
+	methods := []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	if i.Keys == nil {
+		if i.Method == nil {
+			return nil, ErrNoSigningMethod
+		}
+		methods = []string{i.Method.Alg()}
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(i.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(i.clock),
+	}
+	if len(i.Audience) > 0 {
+		options = append(options, jwt.WithAudience(i.Audience...))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.NewParser(options...).ParseWithClaims(rawToken, claims, i.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return Claims(claims), nil
+}
+
+// UserFromClaims rebuilds the authCommon.User an access token was issued for
+func UserFromClaims(claims Claims) *authCommon.User {
+	return &authCommon.User{
+		Provider:      claims.String("provider"),
+		Subject:       claims.String("sub"),
+		Email:         claims.String("email"),
+		EmailVerified: claims.Bool("email_verified"),
+		Name:          claims.String("name"),
+		GivenName:     claims.String("given_name"),
+		FamilyName:    claims.String("family_name"),
+		Picture:       claims.String("picture"),
+		Locale:        claims.String("locale"),
+		Raw:           claims,
+	}
+}
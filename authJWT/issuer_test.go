@@ -0,0 +1,132 @@
+package authJWT
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+)
+
+var bob = &authCommon.User{Provider: "google", Subject: "42", Email: "bob@example.com", EmailVerified: true, Name: "Bob"}
+
+func Test_Issuer(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	issuers := map[string]*Issuer{
+		"HS256": NewHS256Issuer([]byte("secret"), "https://api.example.com", "spa"),
+		"RS256": NewRS256Issuer(rsaKey, "https://api.example.com", "spa"),
+		"EdDSA": NewEdDSAIssuer(edKey, "https://api.example.com", "spa"),
+	}
+	for name, issuer := range issuers {
+		t.Run(name, func(t *testing.T) {
+			issuer.TTL = time.Minute
+			issuer.Claims = map[string]interface{}{"tenant": "acme", "exp": 0}
+			issuer.ClaimsFunc = func(ctx context.Context, user *authCommon.User) (map[string]interface{}, error) {
+				return map[string]interface{}{"roles": []string{"admin"}}, nil
+			}
+
+			token, err := issuer.Issue(context.Background(), bob)
+			assert.NoError(t, err)
+			claims, err := issuer.Verify(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "42", claims.String("sub"))
+			assert.Equal(t, "acme", claims.String("tenant"))
+			assert.Equal(t, []interface{}{"admin"}, claims["roles"])
+			assert.Equal(t, bob.Email, UserFromClaims(claims).Email)
+
+			// Custom claims don't override the expiry
+			issuer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+			_, err = issuer.Verify(token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+			issuer.now = nil
+		})
+	}
+}
+
+func Test_Issuer_Invalid(t *testing.T) {
+	issuer := NewHS256Issuer([]byte("secret"), "https://api.example.com", "spa")
+	token, _ := issuer.Issue(context.Background(), bob)
+
+	for _, other := range []*Issuer{
+		NewHS256Issuer([]byte("other"), "https://api.example.com", "spa"),
+		NewHS256Issuer([]byte("secret"), "https://other.example.com", "spa"),
+		NewHS256Issuer([]byte("secret"), "https://api.example.com", "mobile"),
+	} {
+		_, err := other.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+}
+
+func Test_Issuer_NoSigningMethod(t *testing.T) {
+	issuer := &Issuer{Issuer: "https://api.example.com"}
+	_, err := issuer.Issue(context.Background(), bob)
+	assert.Equal(t, ErrNoSigningMethod, err)
+	_, err = issuer.Verify("token")
+	assert.Equal(t, ErrNoSigningMethod, err)
+	code, _ := authUtils.ErrorCode(err)
+	assert.Equal(t, authUtils.CodeInternalError, code)
+}
+
+func Test_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{ErrInvalidToken, authUtils.CodeUnauthenticated},
+		{ErrUnknownKey, authUtils.CodeUnauthenticated},
+		{ErrNoSigningKey, authUtils.CodeInternalError},
+		{ErrNoSigningMethod, authUtils.CodeInternalError},
+	}
+	for _, tt := range tests {
+		code, _ := authUtils.ErrorCode(tt.err)
+		assert.Equal(t, tt.code, code, tt.err.Error())
+	}
+}
+
+func Test_Handler_BearerMiddleware(t *testing.T) {
+	issuer := NewHS256Issuer([]byte("secret"), "https://api.example.com")
+
+	// Issue after the callback
+	var issued string
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		issued, _ = TokenFromContext(req.Context())
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/callback", nil)
+	issuer.Handler("", success, nil).ServeHTTP(w, req.WithContext(authCommon.UserToContext(req.Context(), bob)))
+	assert.NotEmpty(t, issued)
+	assert.Equal(t, issued, w.Header().Get(DefaultHeader))
+
+	// Use it on /graphql
+	var user *authCommon.User
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _ = authCommon.UserFromContext(req.Context())
+	})
+	req = httptest.NewRequest("POST", "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer "+issued)
+	issuer.BearerMiddleware(next, nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "42", user.Subject)
+	assert.Equal(t, "google", user.Provider)
+
+	// Anonymous requests continue
+	user = nil
+	req = httptest.NewRequest("POST", "/graphql", nil)
+	issuer.BearerMiddleware(next, nil).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Nil(t, user)
+
+	// Invalid tokens are rejected
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer "+issued+"x")
+	issuer.BearerMiddleware(next, nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+}
@@ -0,0 +1,239 @@
+package authJWT
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authOIDC"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Error messages
+var (
+	ErrNoSigningKey = authUtils.NewError("jwt: the key set has no signing key", authUtils.CodeInternalError)
+	ErrUnknownKey   = authUtils.NewError("jwt: no key matches the token kid", authUtils.CodeUnauthenticated)
+)
+
+// DefaultRetention is how long a retired key keeps verifying tokens and stays published
+const DefaultRetention = 2 * DefaultTTL
+
+// SigningKey is a private key of a KeySet, identified by the kid header of the tokens it signs
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Key       crypto.Signer
+	CreatedAt time.Time
+	// RetiredAt is zero for the current key
+	RetiredAt time.Time
+}
+
+// KeyGenerator returns a new private key and the method it signs with
+type KeyGenerator func() (crypto.Signer, jwt.SigningMethod, error)
+
+// RS256Generator generates RSA keys of bits for RS256
+func RS256Generator(bits int) KeyGenerator {
+	return func() (crypto.Signer, jwt.SigningMethod, error) {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		return key, jwt.SigningMethodRS256, err
+	}
+}
+
+// EdDSAGenerator generates Ed25519 keys for EdDSA
+func EdDSAGenerator() (crypto.Signer, jwt.SigningMethod, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, jwt.SigningMethodEdDSA, err
+}
+
+// KeySet :
+// - Holds the asymmetric signing keys of an Issuer: the current one and the recently retired ones
+// - Tokens are signed with the current key and carry its kid header
+// - Retired keys keep verifying tokens, and stay published in the JWKS, for Retention
+// - Retention is raised to the longest TTL of the tokens it signed, so they verify until they expire
+type KeySet struct {
+	Generate  KeyGenerator
+	Retention time.Duration
+
+	mu   sync.RWMutex
+	keys []*SigningKey // current first
+	ttl  time.Duration // longest TTL of the signed tokens
+	now  func() time.Time
+}
+
+// NewKeySet returns a KeySet with a first key made by generate
+func NewKeySet(generate KeyGenerator) (*KeySet, error) {
+	s := &KeySet{Generate: generate, Retention: DefaultRetention, now: time.Now}
+	if err := s.Rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add :
+// - Makes key the current signing key, so keys loaded from disk or a KMS can be used
+// - The previous current key is retired
+func (s *KeySet) Add(key *SigningKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock()
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = now
+	}
+	if len(s.keys) > 0 && s.keys[0].RetiredAt.IsZero() {
+		s.keys[0].RetiredAt = now
+	}
+	s.keys = append([]*SigningKey{key}, s.keys...)
+	s.prune(now)
+}
+
+// Rotate :
+// - Generates a new current key, the previous one is retired
+// - Keys retired for longer than Retention are dropped
+func (s *KeySet) Rotate() error {
+	signer, method, err := s.Generate()
+	if err != nil {
+		return err
+	}
+	s.Add(&SigningKey{ID: newTokenID(), Method: method, Key: signer})
+	return nil
+}
+
+// RotateEvery :
+// - Rotates the keys every interval
+// - Returns when ctx is done
+func (s *KeySet) RotateEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Rotate()
+		}
+	}
+}
+
+// clock returns the time of the KeySet, its Issuer uses it too
+func (s *KeySet) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// retention returns how long the retired keys are kept, s.mu must be held
+func (s *KeySet) retention() time.Duration {
+	if s.ttl > s.Retention {
+		return s.ttl
+	}
+	return s.Retention
+}
+
+// prune drops the keys retired for longer than the retention, s.mu must be held
+func (s *KeySet) prune(now time.Time) {
+	retention := s.retention()
+	keys := s.keys[:0]
+	for _, key := range s.keys {
+		if key.RetiredAt.IsZero() || now.Sub(key.RetiredAt) <= retention {
+			keys = append(keys, key)
+		}
+	}
+	s.keys = keys
+}
+
+// live returns the current and the retained keys
+func (s *KeySet) live() []*SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now, retention := s.clock(), s.retention()
+	keys := make([]*SigningKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.RetiredAt.IsZero() || now.Sub(key.RetiredAt) <= retention {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Current returns the key new tokens are signed with
+func (s *KeySet) Current() (*SigningKey, error) {
+	return s.current(0)
+}
+
+// current returns the current key, and keeps it for at least ttl, the lifetime of the token it signs
+func (s *KeySet) current(ttl time.Duration) (*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl > s.ttl {
+		s.ttl = ttl
+	}
+	if len(s.keys) == 0 || !s.keys[0].RetiredAt.IsZero() {
+		return nil, ErrNoSigningKey
+	}
+	return s.keys[0], nil
+}
+
+// signingKey returns the current or retained key identified by kid
+func (s *KeySet) signingKey(kid string) (*SigningKey, error) {
+	for _, key := range s.live() {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Key :
+// - Returns the public key identified by kid
+// - Implements authOIDC.KeySource, so the services of this process can verify tokens with an authOIDC.Verifier
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, err := s.signingKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return key.Key.Public(), nil
+}
+
+// JWKS returns the JSON Web Key Set of the current and retained keys
+func (s *KeySet) JWKS() (authOIDC.JSONWebKeySet, error) {
+	set := authOIDC.JSONWebKeySet{Keys: []authOIDC.JSONWebKey{}}
+	for _, key := range s.live() {
+		jwk, err := authOIDC.NewJSONWebKey(key.ID, key.Method.Alg(), key.Key.Public())
+		if err != nil {
+			return set, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// JWKSPath is where the JSON Web Key Set is usually served
+const JWKSPath = "/.well-known/jwks.json"
+
+// Handler :
+// - Serves the JSON Web Key Set, mount it at JWKSPath
+// - Other services verify the issued tokens with it, for instance with authOIDC.NewRemoteKeySet
+func (s *KeySet) Handler() http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		set, err := s.JWKS()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(set)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,83 @@
+package authJWT
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authOIDC"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KeySet_Rotate(t *testing.T) {
+	keys, err := NewKeySet(EdDSAGenerator)
+	assert.NoError(t, err)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	issuer := NewKeySetIssuer(keys, "https://api.example.com", "spa")
+
+	old, _ := issuer.Issue(context.Background(), bob)
+	assert.NoError(t, keys.Rotate())
+	current, _ := issuer.Issue(context.Background(), bob)
+
+	// Tokens of the retired key are still valid, and both keys are published
+	_, err = issuer.Verify(old)
+	assert.NoError(t, err)
+	_, err = issuer.Verify(current)
+	assert.NoError(t, err)
+	set, _ := keys.JWKS()
+	assert.Len(t, set.Keys, 2)
+
+	// Until the retention is over
+	now = now.Add(DefaultRetention + time.Second)
+	current, _ = issuer.Issue(context.Background(), bob)
+	_, err = issuer.Verify(old)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = issuer.Verify(current)
+	assert.NoError(t, err)
+	set, _ = keys.JWKS()
+	assert.Len(t, set.Keys, 1)
+}
+
+func Test_KeySet_RetentionShorterThanTTL(t *testing.T) {
+	keys, _ := NewKeySet(EdDSAGenerator)
+	keys.Retention = time.Minute
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	issuer := NewKeySetIssuer(keys, "https://api.example.com", "spa")
+	issuer.TTL = time.Hour
+
+	// The retired key is kept until the tokens it signed expire
+	token, _ := issuer.Issue(context.Background(), bob)
+	assert.NoError(t, keys.Rotate())
+	now = now.Add(30 * time.Minute)
+	_, err := issuer.Verify(token)
+	assert.NoError(t, err)
+	set, _ := keys.JWKS()
+	assert.Len(t, set.Keys, 2)
+
+	now = now.Add(31 * time.Minute)
+	set, _ = keys.JWKS()
+	assert.Len(t, set.Keys, 1)
+}
+
+func Test_KeySet_Handler(t *testing.T) {
+	keys, _ := NewKeySet(RS256Generator(2048))
+	issuer := NewKeySetIssuer(keys, "https://api.example.com", "spa")
+	srv := httptest.NewServer(keys.Handler())
+	defer srv.Close()
+
+	old, _ := issuer.Issue(context.Background(), bob)
+	keys.Rotate()
+	current, _ := issuer.Issue(context.Background(), bob)
+
+	// Another service verifies the tokens with the published keys only
+	verifier := authOIDC.NewVerifier("https://api.example.com", "spa", srv.URL+JWKSPath)
+	verifier.Algorithms = []string{"RS256"}
+	for _, token := range []string{old, current} {
+		claims, err := verifier.Verify(context.Background(), token, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "42", claims.String("sub"))
+	}
+}
@@ -0,0 +1,25 @@
+package authLogout
+
+import (
+	"context"
+	"fmt"
+)
+
+type key int
+
+const (
+	ResultKey key = iota
+)
+
+func ResultToContext(ctx context.Context, result *Result) context.Context {
+	return context.WithValue(ctx, ResultKey, result)
+}
+
+// ResultFromContext returns the Result of the logout of the request
+func ResultFromContext(ctx context.Context) (*Result, error) {
+	result, ok := ctx.Value(ResultKey).(*Result)
+	if !ok {
+		return nil, fmt.Errorf("logout: Context missing logout Result")
+	}
+	return result, nil
+}
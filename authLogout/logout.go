@@ -0,0 +1,195 @@
+package authLogout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authSession"
+	"github.com/astenmies/graphql-go-auth/authToken"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Result :
+// - What a logout did, see ResultFromContext
+// - User is the user that signed out, nil if nobody was signed in
+// - RevocationErr is set when the provider token couldn't be revoked, the local logout is done anyway
+type Result struct {
+	User          *authCommon.User
+	Revoked       bool
+	RevocationErr error
+}
+
+// DefaultRevocationTimeout bounds the call to the provider that revokes the token
+const DefaultRevocationTimeout = 5 * time.Second
+
+// Logout :
+// - Signs the user out: destroys its session, expires the auth cookies and revokes its provider token
+// - Sessions, Tokens and Providers are optional
+// - Cookies are the other cookies to expire, such as the state cookie
+// - RevocationTimeout bounds the revocation, DefaultRevocationTimeout when zero
+type Logout struct {
+	Sessions          *authSession.Manager
+	Tokens            authToken.TokenStore
+	Providers         *authCommon.Registry
+	Cookies           []*authUtils.Config
+	RevocationTimeout time.Duration
+}
+
+// NewLogout returns a Logout of sessions, revoking the tokens of store with the providers of registry
+func NewLogout(sessions *authSession.Manager, store authToken.TokenStore, registry *authCommon.Registry, cookies ...*authUtils.Config) *Logout {
+	return &Logout{Sessions: sessions, Tokens: store, Providers: registry, Cookies: cookies}
+}
+
+// user returns the user of req, from the ctx or from its session
+func (l *Logout) user(req *http.Request) *authCommon.User {
+	if user, err := authCommon.UserFromContext(req.Context()); err == nil {
+		return user
+	}
+	if l.Sessions != nil {
+		if session, err := l.Sessions.Load(req); err == nil {
+			return session.User
+		}
+	}
+	return nil
+}
+
+// revoke revokes and deletes the stored token of user, the provider gets at most RevocationTimeout
+func (l *Logout) revoke(ctx context.Context, user *authCommon.User) (bool, error) {
+	if l.Tokens == nil {
+		return false, nil
+	}
+	userID := user.ID()
+	token, err := l.Tokens.Get(ctx, userID, user.Provider)
+	if err == authToken.ErrTokenNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// The token is deleted even if it can't be revoked, the user is signed out locally
+	defer l.Tokens.Delete(ctx, userID, user.Provider)
+
+	if l.Providers == nil {
+		return false, nil
+	}
+	provider, err := l.Providers.Get(user.Provider)
+	if err != nil {
+		return false, err
+	}
+	revoker, ok := provider.(authCommon.Revoker)
+	if !ok {
+		return false, nil
+	}
+	timeout := l.RevocationTimeout
+	if timeout == 0 {
+		timeout = DefaultRevocationTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := revoker.Revoke(ctx, token); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Logout :
+// - Destroys the session of req and expires the session cookie and Cookies with MaxAge<0
+// - Then revokes the stored provider token of the user, see Result.RevocationErr. The user is already
+// signed out locally, a slow provider only delays the response by RevocationTimeout
+// - Returns an error only if the session couldn't be deleted, the cookies are expired anyway
+func (l *Logout) Logout(w http.ResponseWriter, req *http.Request) (*Result, error) {
+	result := &Result{User: l.user(req)}
+	for _, config := range l.Cookies {
+		http.SetCookie(w, authUtils.ExpiredCookie(config))
+	}
+	var err error
+	if l.Sessions != nil {
+		err = l.Sessions.Destroy(w, req)
+	}
+	if result.User != nil {
+		result.Revoked, result.RevocationErr = l.revoke(req.Context(), result.User)
+	}
+	return result, err
+}
+
+// Handler :
+// - Signs the user out, mount it at a route such as /logout
+// - Only POST requests are accepted, others get 405 Method Not Allowed, so a cross-site
+// <img src="/logout"> can't sign the user out and revoke its grant
+// - Adds the Result to the ctx and the success handler is called,
+// it answers 204 No Content when success is nil
+// - Otherwise, the failure handler is called
+func (l *Logout) Handler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := req.Context()
+		result, err := l.Logout(w, req)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = ResultToContext(ctx, result)
+		if success == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Middleware :
+// - Signs the user out when the GraphQL request calls config.LogoutMutation,
+// detected like config.TriggerMutation
+// - Adds the Result to the ctx, so the resolver of the mutation can return it
+// - Other requests go to next untouched
+// - If the session can't be deleted, the failure handler is called
+func (l *Logout) Middleware(config *authUtils.Config, next http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		var buf []byte
+		if req.Body != nil {
+			buf, _ = ioutil.ReadAll(req.Body)
+		}
+		// Restore the body for next
+		req.Body = ioutil.NopCloser(bytes.NewBuffer(buf))
+
+		var t struct {
+			Query         string `json:"query"`
+			OperationName string `json:"operationName"`
+		}
+		json.Unmarshal(buf, &t)
+		if !authCommon.IsLogout(config, t.Query, t.OperationName) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		result, err := l.Logout(w, req)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = ResultToContext(ctx, result)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,169 @@
+package authLogout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authGoogle"
+	"github.com/astenmies/graphql-go-auth/authSession"
+	"github.com/astenmies/graphql-go-auth/authToken"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var stateConfig = &authUtils.Config{Name: "state", Path: "/", LogoutMutation: "logout"}
+
+type testLogout struct {
+	*Logout
+	revoked []string
+	cookie  *http.Cookie
+}
+
+// newTestLogout returns a Logout with a signed in user, whose Google token is revoked by a local server answering status
+func newTestLogout(t *testing.T, status int) (*testLogout, func()) {
+	l := &testLogout{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		l.revoked = append(l.revoked, req.Form.Get("token"))
+		w.WriteHeader(status)
+	}))
+	google := authGoogle.NewProvider(&oauth2.Config{})
+	google.RevocationURL = srv.URL
+
+	keyring := authUtils.NewKeyring([]byte("secret"))
+	sessions := authSession.NewManager(authSession.NewMemoryStore(), &authUtils.Config{Name: "session", Path: "/", Keyring: keyring})
+	tokens := authToken.NewMemoryStore(keyring)
+	l.Logout = NewLogout(sessions, tokens, authCommon.NewRegistry(google), stateConfig)
+
+	user := &authCommon.User{Provider: "google", Subject: "bob"}
+	w := httptest.NewRecorder()
+	_, err := sessions.Create(w, httptest.NewRequest("GET", "/callback", nil), user)
+	assert.NoError(t, err)
+	l.cookie = w.Result().Cookies()[0]
+	tokens.Save(context.Background(), "google:bob", "google", &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"})
+	return l, srv.Close
+}
+
+// expired returns the names of the cookies w expires
+func expired(w *httptest.ResponseRecorder) []string {
+	var names []string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			names = append(names, cookie.Name)
+		}
+	}
+	return names
+}
+
+func Test_Logout_Handler(t *testing.T) {
+	l, stop := newTestLogout(t, http.StatusOK)
+	defer stop()
+
+	// A cross-site GET doesn't sign the user out
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/logout", nil)
+	req.AddCookie(l.cookie)
+	l.Handler(nil, nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
+	assert.Empty(t, l.revoked)
+	_, err := l.Sessions.Load(req)
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(l.cookie)
+	l.Handler(nil, nil).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.ElementsMatch(t, []string{"state", "session"}, expired(w))
+	assert.Equal(t, []string{"refresh"}, l.revoked)
+	_, err = l.Tokens.Get(context.Background(), "google:bob", "google")
+	assert.Equal(t, authToken.ErrTokenNotFound, err)
+	req = httptest.NewRequest("POST", "/graphql", nil)
+	req.AddCookie(l.cookie)
+	_, err = l.Sessions.Load(req)
+	assert.Error(t, err)
+}
+
+func Test_Logout_RevocationFailure(t *testing.T) {
+	l, stop := newTestLogout(t, http.StatusServiceUnavailable)
+	defer stop()
+
+	var result *Result
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		result, _ = ResultFromContext(req.Context())
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(l.cookie)
+	l.Handler(success, nil).ServeHTTP(w, req)
+
+	// Reported, but the user is signed out locally
+	assert.Equal(t, "bob", result.User.Subject)
+	assert.False(t, result.Revoked)
+	assert.Equal(t, authGoogle.ErrUnableToRevokeGoogleToken, result.RevocationErr)
+	assert.ElementsMatch(t, []string{"state", "session"}, expired(w))
+	_, err := l.Tokens.Get(context.Background(), "google:bob", "google")
+	assert.Equal(t, authToken.ErrTokenNotFound, err)
+}
+
+func Test_Logout_SlowRevocation(t *testing.T) {
+	l, stop := newTestLogout(t, http.StatusOK)
+	defer stop()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	provider, _ := l.Providers.Get("google")
+	provider.(*authGoogle.Provider).RevocationURL = slow.URL
+	l.RevocationTimeout = 50 * time.Millisecond
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(l.cookie)
+	start := time.Now()
+	result, err := l.Logout.Logout(httptest.NewRecorder(), req)
+
+	// The provider doesn't hold the local logout
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, authGoogle.ErrUnableToRevokeGoogleToken, result.RevocationErr)
+	_, err = l.Sessions.Load(req)
+	assert.Error(t, err)
+}
+
+func Test_Logout_Middleware(t *testing.T) {
+	l, stop := newTestLogout(t, http.StatusOK)
+	defer stop()
+
+	var result *Result
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		result, _ = ResultFromContext(req.Context())
+	})
+	handler := l.Sessions.Middleware(l.Middleware(stateConfig, next, nil))
+
+	// Other operations are untouched
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ me }"}`))
+	req.AddCookie(l.cookie)
+	handler.ServeHTTP(w, req)
+	assert.Nil(t, result)
+	assert.Empty(t, expired(w))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"mutation SignOut { bye: logout }"}`))
+	req.AddCookie(l.cookie)
+	handler.ServeHTTP(w, req)
+	assert.True(t, result.Revoked)
+	assert.Equal(t, "bob", result.User.Subject)
+	assert.ElementsMatch(t, []string{"state", "session"}, expired(w))
+}
@@ -0,0 +1,65 @@
+package authOIDC
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnableToDiscover = authUtils.NewError("oidc: unable to read the OpenID configuration", authUtils.CodeUpstreamError)
+	ErrIssuerMismatch   = authUtils.NewError("oidc: issuer of the OpenID configuration does not match", authUtils.CodeUpstreamError)
+)
+
+// Discovery is the OpenID Provider configuration, as served at /.well-known/openid-configuration
+type Discovery struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	RevocationEndpoint               string   `json:"revocation_endpoint"`
+	EndSessionEndpoint               string   `json:"end_session_endpoint"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Endpoint returns the oauth2.Endpoint of the provider
+func (d *Discovery) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  d.AuthorizationEndpoint,
+		TokenURL: d.TokenEndpoint,
+	}
+}
+
+// Discover :
+// - Reads the OpenID configuration of issuer
+// - The http.Client can be set with the oauth2.HTTPClient ctx key
+// - Returns ErrIssuerMismatch if the configuration is not the one of issuer
+func Discover(ctx context.Context, issuer string) (*Discovery, error) {
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := oauth2.NewClient(ctx, nil).Get(wellKnown)
+	if err != nil {
+		return nil, ErrUnableToDiscover
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrUnableToDiscover
+	}
+
+	var discovery Discovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, ErrUnableToDiscover
+	}
+	if discovery.Issuer != issuer {
+		return nil, ErrIssuerMismatch
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, ErrUnableToDiscover
+	}
+	return &discovery, nil
+}
@@ -0,0 +1,25 @@
+package authOIDC
+
+import (
+	"context"
+	"fmt"
+)
+
+type key int
+
+const (
+	ClaimsKey key = iota
+)
+
+func ClaimsToContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ClaimsKey, claims)
+}
+
+// ClaimsFromContext returns the verified id_token claims from ctx
+func ClaimsFromContext(ctx context.Context) (Claims, error) {
+	claims, ok := ctx.Value(ClaimsKey).(Claims)
+	if !ok {
+		return nil, fmt.Errorf("oidc: Context missing id_token claims")
+	}
+	return claims, nil
+}
@@ -0,0 +1,269 @@
+package authOIDC
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnableToGetKeys = authUtils.NewError("oidc: unable to get the JSON Web Key Set", authUtils.CodeUpstreamError)
+	ErrUnknownKey      = authUtils.NewError("oidc: no key matches the token", authUtils.CodeUnauthenticated)
+	ErrUnsupportedKey  = authUtils.NewError("oidc: unsupported JSON Web Key", authUtils.CodeUpstreamError)
+)
+
+// JSONWebKey is a public key of a JSON Web Key Set
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a JSON Web Key Set, as served at a jwks_uri
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// PublicKey :
+// - Returns the *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey of the key
+// - Returns ErrUnsupportedKey for other key types
+func (k *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, ErrUnsupportedKey
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, ErrUnsupportedKey
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKey
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, ErrUnsupportedKey
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, ErrUnsupportedKey
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// NewJSONWebKey :
+// - Returns the JSON Web Key of an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+// - kid and alg are published with it, so verifiers pick the right key
+// - Returns ErrUnsupportedKey for other key types
+func NewJSONWebKey(kid, alg string, key crypto.PublicKey) (JSONWebKey, error) {
+	jwk := JSONWebKey{Kid: kid, Use: "sig", Alg: alg}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeInt(key.N)
+		jwk.E = encodeInt(big.NewInt(int64(key.E)))
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return JSONWebKey{}, ErrUnsupportedKey
+	}
+	return jwk, nil
+}
+
+// RemoteKeySet :
+// - Fetches and caches the JSON Web Key Set served at URL
+// - The cache is refreshed after CacheTTL, or when a token refers to an unknown key id (key rollover)
+// - Refreshes are at most once per MinRefreshInterval, so unknown key ids can't flood the provider
+// - The set is fetched without holding the lock, concurrent callers wait for the same fetch
+// and lookups of cached keys are never held by a slow provider
+type RemoteKeySet struct {
+	URL                string
+	CacheTTL           time.Duration
+	MinRefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	fetching  *keyFetch
+	now       func() time.Time
+}
+
+// keyFetch is a fetch of the set in progress, done is closed once err is set
+type keyFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteKeySet returns a RemoteKeySet for the JSON Web Key Set served at url
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:                url,
+		CacheTTL:           time.Hour,
+		MinRefreshInterval: 10 * time.Second,
+		now:                time.Now,
+	}
+}
+
+// Key :
+// - Returns the public key identified by kid
+// - When kid is empty and the set holds a single key, returns that key
+// - The http.Client can be set with the oauth2.HTTPClient ctx key
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	now := s.now()
+	stale := s.keys == nil || now.Sub(s.fetchedAt) > s.CacheTTL
+	if !stale {
+		if key, ok := s.lookup(kid); ok {
+			s.mu.Unlock()
+			return key, nil
+		}
+	}
+	// Unknown key id: the provider may have rolled its keys over
+	if stale || now.Sub(s.fetchedAt) >= s.MinRefreshInterval {
+		fetch, leader := s.fetching, s.fetching == nil
+		if leader {
+			fetch = &keyFetch{done: make(chan struct{})}
+			s.fetching = fetch
+		}
+		s.mu.Unlock()
+		if leader {
+			s.refresh(ctx, fetch)
+		}
+
+		var err error
+		select {
+		case <-fetch.done:
+			err = fetch.err
+		case <-ctx.Done():
+			err = ErrUnableToGetKeys
+		}
+		s.mu.Lock()
+		if err != nil {
+			// Keep using the cached keys while the provider is unreachable
+			key, ok := s.lookup(kid)
+			s.mu.Unlock()
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+	defer s.mu.Unlock()
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *RemoteKeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the set, then swaps the keys in under the lock and ends fetch
+func (s *RemoteKeySet) refresh(ctx context.Context, fetch *keyFetch) {
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = s.now()
+	}
+	s.fetching = nil
+	fetch.err = err
+	close(fetch.done)
+}
+
+// fetch fetches the JSON Web Key Set, keys that can't be used to sign are skipped
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	resp, err := oauth2.NewClient(ctx, nil).Get(s.URL)
+	if err != nil {
+		return nil, ErrUnableToGetKeys
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrUnableToGetKeys
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, ErrUnableToGetKeys
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i := range set.Keys {
+		if set.Keys[i].Use != "" && set.Keys[i].Use != "sig" {
+			continue
+		}
+		key, err := set.Keys[i].PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[set.Keys[i].Kid] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,140 @@
+package authOIDC
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+// DefaultProviderName is the name of a Provider created without a name
+const DefaultProviderName = "oidc"
+
+// Provider :
+// - OpenID Connect implementation of authCommon.Provider, for Keycloak, Okta, Auth0, Dex...
+// - Its oauth2 config endpoints come from the OpenID configuration of the issuer
+// - Users are built from the verified id_token claims
+type Provider struct {
+	name      string
+	config    *oauth2.Config
+	Discovery *Discovery
+	Verifier  *Verifier
+}
+
+// NewProvider :
+// - Reads the OpenID configuration of issuer
+// - Sets the endpoints of config and adds the "openid" scope if missing
+// - name identifies the provider in a authCommon.Registry, DefaultProviderName when empty
+func NewProvider(ctx context.Context, name string, issuer string, config *oauth2.Config) (*Provider, error) {
+	discovery, err := Discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = DefaultProviderName
+	}
+
+	config.Endpoint = discovery.Endpoint()
+	hasOpenID := false
+	for _, scope := range config.Scopes {
+		hasOpenID = hasOpenID || scope == "openid"
+	}
+	if !hasOpenID {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+
+	verifier := NewVerifier(discovery.Issuer, config.ClientID, discovery.JWKSURI)
+	verifier.Algorithms = discovery.IDTokenSigningAlgValuesSupported
+	return &Provider{
+		name:      name,
+		config:    config,
+		Discovery: discovery,
+		Verifier:  verifier,
+	}, nil
+}
+
+// Name returns the name of the provider
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Config returns the oauth2 config of the provider
+func (p *Provider) Config() *oauth2.Config {
+	return p.config
+}
+
+// AuthCodeOptions sends the nonce of the flow in ctx on the auth URL, see authCommon.ProviderLoginHandler
+func (p *Provider) AuthCodeOptions(ctx context.Context) []oauth2.AuthCodeOption {
+	return authCommon.NonceOptions(ctx)
+}
+
+// User verifies the id_token of token and normalizes its claims
+func (p *Provider) User(ctx context.Context, token *oauth2.Token) (*authCommon.User, error) {
+	claims, err := p.verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeClaims(p.name, claims), nil
+}
+
+// verify verifies the id_token of token against the nonce of the flow in ctx
+func (p *Provider) verify(ctx context.Context, token *oauth2.Token) (Claims, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+	nonce, err := authCommon.NonceFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.Verifier.Verify(ctx, rawIDToken, nonce)
+}
+
+// Handler :
+// - Gets the OAuth2 Token from the ctx
+// - Verifies its id_token
+// - Adds the claims and the normalized authCommon.User to the ctx and the success handler is called
+// - Otherwise, the failure handler is called
+func (p *Provider) Handler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := authCommon.TokenFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := p.verify(ctx, token)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = ClaimsToContext(ctx, claims)
+		ctx = authCommon.UserToContext(ctx, NormalizeClaims(p.name, claims))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// NormalizeClaims maps standard OpenID Connect claims to an authCommon.User
+func NormalizeClaims(provider string, claims Claims) *authCommon.User {
+	return &authCommon.User{
+		Provider:      provider,
+		Subject:       claims.String("sub"),
+		Email:         claims.String("email"),
+		EmailVerified: claims.Bool("email_verified"),
+		Name:          claims.String("name"),
+		GivenName:     claims.String("given_name"),
+		FamilyName:    claims.String("family_name"),
+		Picture:       claims.String("picture"),
+		Locale:        claims.String("locale"),
+		Raw:           claims,
+	}
+}
@@ -0,0 +1,248 @@
+package authOIDC
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// issuer is a fake OpenID Provider
+type issuer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	kid       string
+	key       *rsa.PrivateKey
+	jwksCalls int
+}
+
+func newIssuer(t *testing.T) *issuer {
+	iss := &issuer{}
+	iss.rotate("key-1")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                iss.URL,
+			AuthorizationEndpoint: iss.URL + "/auth",
+			TokenEndpoint:         iss.URL + "/token",
+			JWKSURI:               iss.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, req *http.Request) {
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		iss.jwksCalls++
+		json.NewEncoder(w).Encode(JSONWebKeySet{Keys: []JSONWebKey{{
+			Kty: "RSA",
+			Kid: iss.kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(iss.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(iss.key.E)).Bytes()),
+		}}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	return iss
+}
+
+func (iss *issuer) rotate(kid string) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.kid = kid
+	iss.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+}
+
+func (iss *issuer) sign(claims jwt.MapClaims) string {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = iss.kid
+	raw, _ := token.SignedString(iss.key)
+	return raw
+}
+
+func (iss *issuer) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            iss.URL,
+		"aud":            "client",
+		"sub":            "42",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "bob@example.com",
+		"email_verified": true,
+		"name":           "Bob",
+	}
+}
+
+func callback(provider *Provider, rawIDToken string) (*httptest.ResponseRecorder, *authCommon.User, Claims) {
+	var user *authCommon.User
+	var claims Claims
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _ = authCommon.UserFromContext(req.Context())
+		claims, _ = ClaimsFromContext(req.Context())
+	})
+
+	token := (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]interface{}{"id_token": rawIDToken})
+	ctx := authCommon.TokenToContext(context.Background(), token)
+	ctx = authCommon.VerifierToContext(ctx, "verifier")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	provider.Handler(success, nil).ServeHTTP(w, req.WithContext(ctx))
+	return w, user, claims
+}
+
+func Test_Provider(t *testing.T) {
+	iss := newIssuer(t)
+	defer iss.Close()
+
+	config := &oauth2.Config{ClientID: "client", Scopes: []string{"email"}}
+	provider, err := NewProvider(context.Background(), "dex", iss.URL, config)
+	assert.NoError(t, err)
+	assert.Equal(t, iss.URL+"/token", config.Endpoint.TokenURL)
+	assert.Equal(t, []string{"openid", "email"}, config.Scopes)
+
+	nonce := authCommon.NonceForVerifier("verifier")
+	w, user, claims := callback(provider, iss.sign(iss.claims(nonce)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "dex", user.Provider)
+	assert.Equal(t, "42", user.Subject)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "Bob", claims.String("name"))
+}
+
+func Test_Provider_Invalid(t *testing.T) {
+	iss := newIssuer(t)
+	defer iss.Close()
+
+	provider, err := NewProvider(context.Background(), "", iss.URL, &oauth2.Config{ClientID: "client"})
+	assert.NoError(t, err)
+	nonce := authCommon.NonceForVerifier("verifier")
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		err    error
+	}{
+		{"nonce", func(c jwt.MapClaims) { c["nonce"] = "other" }, ErrNonceMismatch},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }, ErrNonceMismatch},
+		{"issuer", func(c jwt.MapClaims) { c["iss"] = "https://other.example" }, ErrInvalidIDToken},
+		{"audience", func(c jwt.MapClaims) { c["aud"] = "other" }, ErrInvalidIDToken},
+		{"azp", func(c jwt.MapClaims) { c["aud"] = []string{"client", "other"}; c["azp"] = "other" }, ErrInvalidIDToken},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, ErrInvalidIDToken},
+		{"issued in the future", func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }, ErrInvalidIDToken},
+		{"missing iat", func(c jwt.MapClaims) { delete(c, "iat") }, ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := iss.claims(nonce)
+			tt.modify(claims)
+			w, _, _ := callback(provider, iss.sign(claims))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.err.Error())
+		})
+	}
+
+	// Signed with another key
+	other := newIssuer(t)
+	defer other.Close()
+	claims := iss.claims(nonce)
+	w, _, _ := callback(provider, other.sign(claims))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// No id_token
+	w, _, _ = callback(provider, "")
+	assert.Contains(t, w.Body.String(), ErrMissingIDToken.Error())
+}
+
+func Test_RemoteKeySet_Rollover(t *testing.T) {
+	iss := newIssuer(t)
+	defer iss.Close()
+
+	provider, err := NewProvider(context.Background(), "", iss.URL, &oauth2.Config{ClientID: "client"})
+	assert.NoError(t, err)
+	keys := provider.Verifier.Keys.(*RemoteKeySet)
+	keys.MinRefreshInterval = 0
+	nonce := authCommon.NonceForVerifier("verifier")
+
+	// Keys are cached
+	callback(provider, iss.sign(iss.claims(nonce)))
+	callback(provider, iss.sign(iss.claims(nonce)))
+	assert.Equal(t, 1, iss.jwksCalls)
+
+	// A new key id triggers a refresh
+	iss.rotate("key-2")
+	w, _, _ := callback(provider, iss.sign(iss.claims(nonce)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, iss.jwksCalls)
+
+	// Unknown key ids don't refresh more than once per MinRefreshInterval
+	keys.MinRefreshInterval = time.Hour
+	iss.rotate("key-3")
+	callback(provider, iss.sign(iss.claims(nonce)))
+	callback(provider, iss.sign(iss.claims(nonce)))
+	assert.Equal(t, 2, iss.jwksCalls)
+}
+
+func Test_RemoteKeySet_SlowProvider(t *testing.T) {
+	jwk, err := NewJSONWebKey("key-1", "EdDSA", make(ed25519.PublicKey, ed25519.PublicKeySize))
+	assert.NoError(t, err)
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(JSONWebKeySet{Keys: []JSONWebKey{jwk}})
+	}))
+	defer srv.Close()
+	keys := NewRemoteKeySet(srv.URL)
+	keys.MinRefreshInterval = 0
+	_, err = keys.Key(context.Background(), "key-1")
+	assert.NoError(t, err)
+
+	// Unknown key ids wait for the same refresh
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(context.Background(), "key-2")
+			assert.Equal(t, ErrUnknownKey, err)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Cached keys are served meanwhile
+	start := time.Now()
+	_, err = keys.Key(context.Background(), "key-1")
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_Discover_IssuerMismatch(t *testing.T) {
+	iss := newIssuer(t)
+	defer iss.Close()
+
+	_, err := Discover(context.Background(), iss.URL+"/other")
+	assert.Equal(t, ErrUnableToDiscover, err)
+	_, err = Discover(context.Background(), iss.URL+"/")
+	assert.Equal(t, ErrIssuerMismatch, err)
+}
@@ -0,0 +1,133 @@
+package authOIDC
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrMissingIDToken = authUtils.NewError("oidc: token response is missing the id_token", authUtils.CodeUpstreamError)
+	ErrInvalidIDToken = authUtils.NewError("oidc: invalid id_token", authUtils.CodeUnauthenticated)
+	ErrNonceMismatch  = authUtils.NewError("oidc: id_token nonce does not match", authUtils.CodeUnauthenticated)
+)
+
+// DefaultAlgorithms are the signing algorithms accepted by a Verifier with no Algorithms
+var DefaultAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// KeySource returns the public key identified by kid, RemoteKeySet is one
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// Claims are the claims of a verified id_token
+type Claims map[string]interface{}
+
+// String returns the string claim name, or ""
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Bool returns the boolean claim name, or false
+func (c Claims) Bool(name string) bool {
+	b, _ := c[name].(bool)
+	return b
+}
+
+// Verifier :
+// - Verifies id_tokens issued by Issuer for ClientID
+// - Checks the signature with Keys, and the iss, aud, azp, exp, iat and nonce claims
+type Verifier struct {
+	Keys     KeySource
+	Issuer   string
+	ClientID string
+	// AlternateIssuers are also accepted in the iss claim
+	AlternateIssuers []string
+	// Algorithms are the accepted signing algorithms, DefaultAlgorithms when empty
+	Algorithms []string
+	// Leeway is the allowed clock skew for exp and iat
+	Leeway time.Duration
+
+	now func() time.Time
+}
+
+// NewVerifier returns a Verifier using the keys served at jwksURL
+func NewVerifier(issuer, clientID, jwksURL string) *Verifier {
+	return &Verifier{
+		Keys:     NewRemoteKeySet(jwksURL),
+		Issuer:   issuer,
+		ClientID: clientID,
+		Leeway:   time.Minute,
+	}
+}
+
+func (v *Verifier) validIssuer(iss interface{}) bool {
+	if iss == v.Issuer {
+		return true
+	}
+	for _, issuer := range v.AlternateIssuers {
+		if iss == issuer {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify :
+// - Verifies rawIDToken and returns its claims
+// - nonce is the value sent on the auth URL, see authCommon.NonceFromContext
+// - Errors wrap ErrInvalidIDToken, or are ErrNonceMismatch
+func (v *Verifier) Verify(ctx context.Context, rawIDToken string, nonce string) (Claims, error) {
+	algorithms := v.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultAlgorithms
+	}
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(algorithms),
+		jwt.WithAudience(v.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.Leeway),
+		jwt.WithTimeFunc(now),
+	)
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.Keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if !v.validIssuer(claims["iss"]) {
+		return nil, fmt.Errorf("%w: issuer does not match", ErrInvalidIDToken)
+	}
+	if _, ok := claims["iat"]; !ok {
+		return nil, fmt.Errorf("%w: missing iat", ErrInvalidIDToken)
+	}
+	if claims["sub"] == nil || claims["sub"] == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	// With several audiences, the token must have been issued to us
+	if aud, err := claims.GetAudience(); err == nil && len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != v.ClientID {
+			return nil, fmt.Errorf("%w: azp does not match", ErrInvalidIDToken)
+		}
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+	return Claims(claims), nil
+}
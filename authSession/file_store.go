@@ -0,0 +1,148 @@
+package authSession
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore :
+// - SessionStore keeping one JSON file per session in Dir
+// - Sessions survive restarts, Dir can be shared by processes of one host
+type FileStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileStore returns a FileStore in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// path returns the file of the session id, named after its hash so any id is a safe file name
+func (s *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func readSessionFile(path string) (*Session, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(b, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Get reads the session id
+func (s *FileStore) Get(ctx context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return readSessionFile(s.path(id))
+}
+
+// Save writes session to a temporary file and renames it, so readers never see a partial session
+func (s *FileStore) Save(ctx context.Context, session *Session) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := ioutil.TempFile(s.Dir, ".session-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(session.ID))
+}
+
+// Delete removes the session id
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// each calls fn with every session of Dir, unreadable files are skipped
+func (s *FileStore) each(fn func(path string, session *Session) error) error {
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.Dir, file.Name())
+		session, err := readSessionFile(path)
+		if err != nil {
+			continue
+		}
+		if err := fn(path, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpired removes the session files expired at now
+func (s *FileStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	err := s.each(func(path string, session *Session) error {
+		if now.Before(session.ExpiresAt) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ListByUser reads the sessions of userID
+func (s *FileStore) ListByUser(ctx context.Context, userID string) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sessions []*Session
+	err := s.each(func(path string, session *Session) error {
+		if session.UserID() == userID {
+			sessions = append(sessions, session)
+		}
+		return nil
+	})
+	sortSessions(sessions)
+	return sessions, err
+}
@@ -0,0 +1,26 @@
+package authSession
+
+import (
+	"context"
+	"fmt"
+)
+
+type key int
+
+const (
+	SessionKey key = iota
+)
+
+// SessionToContext adds session to ctx
+func SessionToContext(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, SessionKey, session)
+}
+
+// SessionFromContext returns the session from ctx
+func SessionFromContext(ctx context.Context) (*Session, error) {
+	session, ok := ctx.Value(SessionKey).(*Session)
+	if !ok {
+		return nil, fmt.Errorf("session: Context missing Session")
+	}
+	return session, nil
+}
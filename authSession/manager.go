@@ -0,0 +1,249 @@
+package authSession
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error messages
+var (
+	ErrNoSession      = authUtils.NewError("session: request has no session", authUtils.CodeUnauthenticated)
+	ErrSessionExpired = authUtils.NewError("session: session expired", authUtils.CodeUnauthenticated)
+)
+
+// Default lifetimes of a session
+const (
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultAbsoluteTimeout = 24 * time.Hour
+)
+
+// DefaultCookieConfig :
+// - Configures the session cookie
+// - Its Max-Age is set from the absolute lifetime of sessions
+var DefaultCookieConfig = &authUtils.Config{
+	Name:     "graphql-go-auth-session",
+	Path:     "/",
+	HTTPOnly: true,
+	Secure:   true, // HTTPS only
+}
+
+// touchInterval limits how often LastSeenAt is saved, so each request doesn't write to the store
+const touchInterval = time.Minute
+
+// Manager :
+// - Creates a session after a successful login callback
+// - Loads it on later requests and puts its user in the context
+// - Destroys it on logout
+type Manager struct {
+	Store SessionStore
+	// Cookie configures the session cookie, its value is signed with Cookie.Keyring
+	Cookie *authUtils.Config
+	// IdleTimeout expires sessions that were not used for this long
+	IdleTimeout time.Duration
+	// AbsoluteTimeout expires sessions this long after they were created
+	AbsoluteTimeout time.Duration
+
+	now func() time.Time
+}
+
+// NewManager :
+// - Returns a Manager with the default lifetimes
+// - Uses DefaultCookieConfig when cookie is nil
+func NewManager(store SessionStore, cookie *authUtils.Config) *Manager {
+	if cookie == nil {
+		cookie = DefaultCookieConfig
+	}
+	return &Manager{
+		Store:           store,
+		Cookie:          cookie,
+		IdleTimeout:     DefaultIdleTimeout,
+		AbsoluteTimeout: DefaultAbsoluteTimeout,
+		now:             time.Now,
+	}
+}
+
+// newSessionToken returns the random value of a new session cookie
+func newSessionToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sessionID returns the ID of the session of the cookie value token, its SHA-256 hash,
+// so the stores never hold a value that could be replayed as a cookie
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// cookieConfig returns Cookie, living as long as the absolute lifetime of sessions
+func (m *Manager) cookieConfig() *authUtils.Config {
+	config := *m.Cookie
+	config.MaxAge = int(m.AbsoluteTimeout / time.Second)
+	return &config
+}
+
+// expiresAt returns the time session expires, the earliest of its idle and absolute expiry
+func (m *Manager) expiresAt(session *Session) time.Time {
+	idle := session.LastSeenAt.Add(m.IdleTimeout)
+	absolute := session.CreatedAt.Add(m.AbsoluteTimeout)
+	if absolute.Before(idle) {
+		return absolute
+	}
+	return idle
+}
+
+// Create :
+// - Destroys the current session of req if any, so a session id is never reused across logins
+// - Saves a new session for user and sets the session cookie, the store only gets the hash of its value
+func (m *Manager) Create(w http.ResponseWriter, req *http.Request, user *authCommon.User) (*Session, error) {
+	if current, err := m.Load(req); err == nil {
+		m.Store.Delete(req.Context(), current.ID)
+	}
+
+	now := m.now()
+	token := newSessionToken()
+	session := &Session{
+		ID:         sessionID(token),
+		User:       user,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	session.ExpiresAt = m.expiresAt(session)
+	if err := m.Store.Save(req.Context(), session); err != nil {
+		return nil, err
+	}
+
+	cookie, err := authUtils.NewSecureCookie(m.cookieConfig(), token)
+	if err != nil {
+		return nil, err
+	}
+	http.SetCookie(w, cookie)
+	return session, nil
+}
+
+// Load :
+// - Returns the session of req
+// - Returns ErrNoSession if req has no valid session cookie, or an error of the store
+// - Returns ErrSessionExpired (and deletes the session) if it is past its idle or absolute lifetime
+// - Otherwise refreshes its idle lifetime
+func (m *Manager) Load(req *http.Request) (*Session, error) {
+	ctx := req.Context()
+	cookie, err := req.Cookie(m.Cookie.Name)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	token, err := authUtils.DecodeValue(m.cookieConfig(), cookie.Value)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	session, err := m.Store.Get(ctx, sessionID(token))
+	if err == ErrSessionNotFound {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	if !now.Before(m.expiresAt(session)) {
+		m.Store.Delete(ctx, session.ID)
+		return nil, ErrSessionExpired
+	}
+	if now.Sub(session.LastSeenAt) >= touchInterval {
+		session.LastSeenAt = now
+		session.ExpiresAt = m.expiresAt(session)
+		if err := m.Store.Save(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// Destroy :
+// - Deletes the session of req from the store
+// - Expires the session cookie
+func (m *Manager) Destroy(w http.ResponseWriter, req *http.Request) error {
+	http.SetCookie(w, authUtils.ExpiredCookie(m.Cookie))
+	session, err := m.Load(req)
+	if err == ErrNoSession || err == ErrSessionExpired {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return m.Store.Delete(req.Context(), session.ID)
+}
+
+// Sweep :
+// - Removes the expired sessions from the store every interval
+// - Returns when ctx is done
+func (m *Manager) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Store.DeleteExpired(ctx, m.now())
+		}
+	}
+}
+
+// Handler :
+// - Gets the authCommon.User from the ctx, as set by a provider handler
+// - Creates a session for it
+// - Adds the session to the ctx and the success handler is called
+// - Otherwise, the failure handler is called
+func (m *Manager) Handler(success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := authCommon.UserFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		session, err := m.Create(w, req, user)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		ctx = SessionToContext(ctx, session)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Middleware :
+// - Loads the session of each request
+// - Adds the session and its authCommon.User to the ctx, so resolvers can read them
+// - Requests without a valid session continue without them
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		session, err := m.Load(req)
+		if err == nil {
+			ctx = SessionToContext(ctx, session)
+			if session.User != nil {
+				ctx = authCommon.UserToContext(ctx, session.User)
+			}
+		}
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
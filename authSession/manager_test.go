@@ -0,0 +1,120 @@
+package authSession
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestManager() (*Manager, *time.Time) {
+	clock := time.Now()
+	manager := NewManager(NewMemoryStore(), &authUtils.Config{
+		Name:    "session",
+		Path:    "/",
+		Keyring: authUtils.NewKeyring([]byte("secret")),
+	})
+	manager.IdleTimeout = 30 * time.Minute
+	manager.AbsoluteTimeout = 2 * time.Hour
+	manager.now = func() time.Time { return clock }
+	return manager, &clock
+}
+
+// login runs the session Handler after a callback and returns the session cookie
+func login(t *testing.T, manager *Manager) *http.Cookie {
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, err := SessionFromContext(req.Context())
+		assert.NoError(t, err)
+	})
+	ctx := authCommon.UserToContext(httptest.NewRequest("GET", "/", nil).Context(), &authCommon.User{Provider: "google", Subject: "42"})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/callback", nil)
+	manager.Handler(success, nil).ServeHTTP(w, req.WithContext(ctx))
+	return w.Result().Cookies()[0]
+}
+
+// request runs the Middleware and returns the user it found
+func request(manager *Manager, cookie *http.Cookie) *authCommon.User {
+	var user *authCommon.User
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _ = authCommon.UserFromContext(req.Context())
+	})
+	req := httptest.NewRequest("POST", "/graphql", nil)
+	req.AddCookie(cookie)
+	manager.Middleware(next).ServeHTTP(httptest.NewRecorder(), req)
+	return user
+}
+
+func Test_Manager(t *testing.T) {
+	manager, _ := newTestManager()
+	cookie := login(t, manager)
+	assert.Equal(t, "session", cookie.Name)
+	assert.Equal(t, 7200, cookie.MaxAge)
+
+	// The store only holds the hash of the cookie value
+	token, err := authUtils.DecodeValue(manager.cookieConfig(), cookie.Value)
+	assert.NoError(t, err)
+	_, err = manager.Store.Get(context.Background(), token)
+	assert.Equal(t, ErrSessionNotFound, err)
+	session, err := manager.Store.Get(context.Background(), sessionID(token))
+	assert.NoError(t, err)
+	assert.Equal(t, sessionID(token), session.ID)
+
+	user := request(manager, cookie)
+	assert.Equal(t, "42", user.Subject)
+
+	// Forged cookies are ignored
+	assert.Nil(t, request(manager, &http.Cookie{Name: "session", Value: "forged"}))
+}
+
+func Test_Manager_IdleTimeout(t *testing.T) {
+	manager, clock := newTestManager()
+	cookie := login(t, manager)
+
+	// Each use extends the idle lifetime
+	*clock = clock.Add(20 * time.Minute)
+	assert.NotNil(t, request(manager, cookie))
+	*clock = clock.Add(20 * time.Minute)
+	assert.NotNil(t, request(manager, cookie))
+
+	*clock = clock.Add(31 * time.Minute)
+	assert.Nil(t, request(manager, cookie))
+}
+
+func Test_Manager_AbsoluteTimeout(t *testing.T) {
+	manager, clock := newTestManager()
+	cookie := login(t, manager)
+
+	for i := 0; i < 4; i++ {
+		*clock = clock.Add(25 * time.Minute)
+		assert.NotNil(t, request(manager, cookie))
+	}
+	*clock = clock.Add(25 * time.Minute)
+	assert.Nil(t, request(manager, cookie))
+}
+
+func Test_Manager_Destroy(t *testing.T) {
+	manager, _ := newTestManager()
+	cookie := login(t, manager)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(cookie)
+	assert.NoError(t, manager.Destroy(w, req))
+	assert.True(t, w.Result().Cookies()[0].MaxAge < 0)
+
+	assert.Nil(t, request(manager, cookie))
+}
+
+func Test_ErrorCodes(t *testing.T) {
+	for _, err := range []error{ErrNoSession, ErrSessionExpired, ErrSessionNotFound} {
+		code, status := authUtils.ErrorCode(err)
+		assert.Equal(t, authUtils.CodeUnauthenticated, code, err.Error())
+		assert.Equal(t, http.StatusUnauthorized, status, err.Error())
+	}
+}
@@ -0,0 +1,159 @@
+package authSession
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore :
+// - SessionStore keeping sessions in Redis
+// - Each session is a key that Redis expires at ExpiresAt
+// - The ids of the sessions of a user are kept in a set, cleaned by ListByUser and DeleteExpired
+type RedisStore struct {
+	Client redis.UniversalClient
+	// Prefix is prepended to every key
+	Prefix string
+}
+
+// NewRedisStore returns a RedisStore using client and the "session:" prefix
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{Client: client, Prefix: "session:"}
+}
+
+func (s *RedisStore) sessionKey(id string) string {
+	return s.Prefix + "id:" + id
+}
+
+func (s *RedisStore) userKey(userID string) string {
+	return s.Prefix + "user:" + userID
+}
+
+// globEscaper escapes the characters that have a meaning in the patterns of SCAN MATCH
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// maxTxRetries bounds the retries of a transaction whose watched keys keep changing
+const maxTxRetries = 10
+
+// Get reads the session id
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := s.Client.Get(ctx, s.sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save writes session, expiring at session.ExpiresAt, and adds it to the set of its user
+func (s *RedisStore) Save(ctx context.Context, session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return s.Delete(ctx, session.ID)
+	}
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.sessionKey(session.ID), data, ttl)
+		if userID := session.UserID(); userID != "" {
+			pipe.SAdd(ctx, s.userKey(userID), session.ID)
+		}
+		return nil
+	})
+	return err
+}
+
+// Delete :
+// - Removes the session id and its entry in the set of its user
+// - The session key is watched, if a Save changes it meanwhile the transaction is retried with the new session
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	key := s.sessionKey(id)
+	fn := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			if userID := session.UserID(); userID != "" {
+				pipe.SRem(ctx, s.userKey(userID), id)
+			}
+			return nil
+		})
+		return err
+	}
+	for i := 0; i < maxTxRetries; i++ {
+		err := s.Client.Watch(ctx, fn, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
+
+// sessionsOf returns the live sessions of the set key and removes the ids of the gone ones
+func (s *RedisStore) sessionsOf(ctx context.Context, key string, now time.Time) ([]*Session, int, error) {
+	ids, err := s.Client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	var sessions []*Session
+	removed := 0
+	for _, id := range ids {
+		session, err := s.Get(ctx, id)
+		if err != nil && err != ErrSessionNotFound {
+			return nil, removed, err
+		}
+		if err == nil && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+			continue
+		}
+		if err == nil {
+			s.Client.Del(ctx, s.sessionKey(id))
+		}
+		s.Client.SRem(ctx, key, id)
+		removed++
+	}
+	return sessions, removed, nil
+}
+
+// DeleteExpired :
+// - Redis already expires the session keys
+// - Removes the expired ids from the sets of users and returns how many were removed
+func (s *RedisStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	count := 0
+	iter := s.Client.Scan(ctx, 0, globEscaper.Replace(s.userKey(""))+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		_, removed, err := s.sessionsOf(ctx, iter.Val(), now)
+		count += removed
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, iter.Err()
+}
+
+// ListByUser reads the sessions of userID
+func (s *RedisStore) ListByUser(ctx context.Context, userID string) ([]*Session, error) {
+	sessions, _, err := s.sessionsOf(ctx, s.userKey(userID), time.Now())
+	sortSessions(sessions)
+	return sessions, err
+}
@@ -0,0 +1,29 @@
+package authSession
+
+import (
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+)
+
+// Session :
+// - Server-side session issued after a successful login
+// - Its ID is the SHA-256 hash of the value of the (signed) session cookie, every store keys sessions by it
+type Session struct {
+	ID   string           `json:"id"`
+	User *authCommon.User `json:"user"`
+	// CreatedAt bounds the absolute lifetime of the session
+	CreatedAt time.Time `json:"created_at"`
+	// LastSeenAt bounds the idle lifetime of the session
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// ExpiresAt is the time the session expires if it stays idle
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserID returns the id of the session user (see authCommon.User.ID), "" if there is no user
+func (s *Session) UserID() string {
+	if s.User == nil {
+		return ""
+	}
+	return s.User.ID()
+}
@@ -0,0 +1,132 @@
+package authSession
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/internal/authSQL"
+)
+
+// Placeholder is the bind parameter syntax of a SQL driver
+type Placeholder = authSQL.Placeholder
+
+const (
+	// QuestionPlaceholder is "?", used by SQLite and MySQL
+	QuestionPlaceholder = authSQL.QuestionPlaceholder
+	// DollarPlaceholder is "$1", used by PostgreSQL
+	DollarPlaceholder = authSQL.DollarPlaceholder
+)
+
+// SQLStore :
+// - SessionStore keeping sessions in a database/sql table
+// - Sessions survive restarts and are shared by every server using the database
+type SQLStore struct {
+	DB          *sql.DB
+	Table       string
+	Placeholder Placeholder
+}
+
+// NewSQLStore returns a SQLStore using table of db, see CreateTable
+func NewSQLStore(db *sql.DB, table string) *SQLStore {
+	return &SQLStore{DB: db, Table: table}
+}
+
+// bind rewrites query for s.Table and s.Placeholder
+func (s *SQLStore) bind(query string) string {
+	return authSQL.Bind(s.Placeholder, s.Table, query)
+}
+
+// CreateTable creates the sessions table and its index if they don't exist
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, s.bind(`CREATE TABLE IF NOT EXISTS {table} (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		data TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`))
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, s.bind(`CREATE INDEX IF NOT EXISTS {table}_user_id ON {table} (user_id)`))
+	return err
+}
+
+// Get reads the session id
+func (s *SQLStore) Get(ctx context.Context, id string) (*Session, error) {
+	var data string
+	err := s.DB.QueryRowContext(ctx, s.bind(`SELECT data FROM {table} WHERE id = ?`), id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save replaces the row of session in a transaction
+func (s *SQLStore) Save(ctx context.Context, session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.bind(`DELETE FROM {table} WHERE id = ?`), session.ID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind(`INSERT INTO {table} (id, user_id, data, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`),
+		session.ID, session.UserID(), string(data), session.CreatedAt.UnixNano(), session.ExpiresAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes the session id
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	_, err := s.DB.ExecContext(ctx, s.bind(`DELETE FROM {table} WHERE id = ?`), id)
+	return err
+}
+
+// DeleteExpired removes the sessions expired at now
+func (s *SQLStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	result, err := s.DB.ExecContext(ctx, s.bind(`DELETE FROM {table} WHERE expires_at <= ?`), now.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// ListByUser reads the sessions of userID
+func (s *SQLStore) ListByUser(ctx context.Context, userID string) ([]*Session, error) {
+	rows, err := s.DB.QueryContext(ctx, s.bind(`SELECT data FROM {table} WHERE user_id = ? ORDER BY created_at`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []*Session
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,104 @@
+package authSession
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error messages
+var (
+	ErrSessionNotFound = authUtils.NewError("session: session not found", authUtils.CodeUnauthenticated)
+)
+
+// SessionStore :
+// - Keeps the sessions on the server side
+// - Get returns ErrSessionNotFound if there is no session with this id
+// - DeleteExpired removes the sessions whose ExpiresAt is not after now and returns how many were removed
+// - ListByUser returns the sessions whose UserID is userID, ordered by creation time
+type SessionStore interface {
+	Get(ctx context.Context, id string) (*Session, error)
+	Save(ctx context.Context, session *Session) error
+	Delete(ctx context.Context, id string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	ListByUser(ctx context.Context, userID string) ([]*Session, error)
+}
+
+// sortSessions orders sessions by creation time
+func sortSessions(sessions []*Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+}
+
+// MemoryStore :
+// - In-memory SessionStore
+// - Sessions are lost when the process stops
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]Session
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session)}
+}
+
+// Get returns a copy of the session id
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Save stores a copy of session
+func (s *MemoryStore) Save(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+// Delete removes the session id
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteExpired removes the sessions expired at now
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for id, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ListByUser returns copies of the sessions of userID
+func (s *MemoryStore) ListByUser(ctx context.Context, userID string) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var sessions []*Session
+	for _, session := range s.sessions {
+		if session.UserID() == userID {
+			session := session
+			sessions = append(sessions, &session)
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
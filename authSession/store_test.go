@@ -0,0 +1,173 @@
+package authSession
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// testStore checks the SessionStore contract
+func testStore(t *testing.T, store SessionStore) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	bob := &authCommon.User{Provider: "google", Subject: "bob"}
+	alice := &authCommon.User{Provider: "github", Subject: "alice"}
+
+	first := &Session{ID: "first", User: bob, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	second := &Session{ID: "second", User: bob, CreatedAt: now.Add(time.Second), LastSeenAt: now, ExpiresAt: now.Add(2 * time.Hour)}
+	short := &Session{ID: "short", User: alice, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Minute)}
+	for _, session := range []*Session{second, first, short} {
+		assert.NoError(t, store.Save(ctx, session))
+	}
+
+	session, err := store.Get(ctx, "first")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", session.User.Subject)
+	assert.True(t, first.ExpiresAt.Equal(session.ExpiresAt))
+	_, err = store.Get(ctx, "unknown")
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	// Saving again replaces the session
+	first.LastSeenAt = now.Add(time.Minute)
+	assert.NoError(t, store.Save(ctx, first))
+	session, _ = store.Get(ctx, "first")
+	assert.True(t, first.LastSeenAt.Equal(session.LastSeenAt))
+
+	sessions, err := store.ListByUser(ctx, "google:bob")
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "first", sessions[0].ID)
+		assert.Equal(t, "second", sessions[1].ID)
+	}
+
+	count, err := store.DeleteExpired(ctx, now.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = store.Get(ctx, "short")
+	assert.Equal(t, ErrSessionNotFound, err)
+	sessions, _ = store.ListByUser(ctx, "github:alice")
+	assert.Len(t, sessions, 0)
+
+	assert.NoError(t, store.Delete(ctx, "first"))
+	assert.NoError(t, store.Delete(ctx, "first"))
+	sessions, _ = store.ListByUser(ctx, "google:bob")
+	assert.Len(t, sessions, 1)
+}
+
+func Test_MemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func Test_FileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	testStore(t, store)
+}
+
+func Test_SQLStore(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	store := NewSQLStore(db, "sessions")
+	assert.NoError(t, store.CreateTable(context.Background()))
+	testStore(t, store)
+}
+
+func Test_SQLStore_DollarPlaceholder(t *testing.T) {
+	store := &SQLStore{Table: "sessions", Placeholder: DollarPlaceholder}
+	assert.Equal(t, "DELETE FROM sessions WHERE id = $1 AND user_id = $2", store.bind("DELETE FROM {table} WHERE id = ? AND user_id = ?"))
+}
+
+func Test_RedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedisStore(client)
+	testStore(t, store)
+
+	// Redis expires the session keys
+	assert.True(t, server.TTL(store.sessionKey("second")) > time.Hour)
+	server.FastForward(3 * time.Hour)
+	_, err := store.Get(context.Background(), "second")
+	assert.Equal(t, ErrSessionNotFound, err)
+}
+
+func Test_RedisStore_Prefix(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	// Glob characters of the prefix only match themselves
+	store := &RedisStore{Client: client, Prefix: "s[1]*:"}
+	other := &RedisStore{Client: client, Prefix: "s1x:"}
+	now := time.Now()
+	expired := &Session{ID: "expired", User: &authCommon.User{Provider: "google", Subject: "bob"}, ExpiresAt: now.Add(time.Minute)}
+	assert.NoError(t, store.Save(ctx, expired))
+	assert.NoError(t, other.Save(ctx, expired))
+	count, err := store.DeleteExpired(ctx, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	members, _ := client.SMembers(ctx, other.userKey("google:bob")).Result()
+	assert.Equal(t, []string{"expired"}, members)
+}
+
+func Test_RedisStore_DeleteAfterSave(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewRedisStore(client)
+	now := time.Now()
+
+	// The session moves to another user while it's being deleted, the transaction is retried
+	session := &Session{ID: "id", User: &authCommon.User{Provider: "google", Subject: "bob"}, ExpiresAt: now.Add(time.Hour)}
+	assert.NoError(t, store.Save(ctx, session))
+	moved := false
+	client.AddHook(saveOnExec{fn: func() {
+		if !moved {
+			moved = true
+			alice := *session
+			alice.User = &authCommon.User{Provider: "google", Subject: "alice"}
+			server.Set(store.sessionKey("id"), string(mustJSON(t, &alice)))
+			server.SAdd(store.userKey("google:alice"), "id")
+		}
+	}})
+	assert.NoError(t, store.Delete(ctx, "id"))
+	assert.True(t, moved)
+	assert.False(t, server.Exists(store.sessionKey("id")))
+	assert.False(t, server.Exists(store.userKey("google:alice")))
+}
+
+// saveOnExec is a redis hook calling fn before each MULTI/EXEC transaction
+type saveOnExec struct {
+	fn func()
+}
+
+func (h saveOnExec) DialHook(next redis.DialHook) redis.DialHook { return next }
+func (h saveOnExec) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return next
+}
+func (h saveOnExec) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.fn()
+		return next(ctx, cmds)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return b
+}
@@ -0,0 +1,156 @@
+package authToken
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+// Save :
+// - Stores token for userID and provider
+// - Providers such as Google only send a refresh token on the first consent,
+// the stored refresh token is kept when token has none
+func Save(ctx context.Context, store TokenStore, userID, provider string, token *oauth2.Token) error {
+	if token.RefreshToken == "" {
+		if previous, err := store.Get(ctx, userID, provider); err == nil && previous.RefreshToken != "" {
+			copied := *token
+			copied.RefreshToken = previous.RefreshToken
+			token = &copied
+		}
+	}
+	return store.Save(ctx, userID, provider, token)
+}
+
+// Handler :
+// - Gets the oauth2.Token and the authCommon.User from the ctx, as set by the callback and provider handlers
+// - Saves the token, see Save
+// - Then the success handler is called
+// - Otherwise, the failure handler is called
+func Handler(store TokenStore, success http.Handler, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = authUtils.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := authCommon.TokenFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user, err := authCommon.UserFromContext(ctx)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		err = Save(ctx, store, user.ID(), user.Provider, token)
+		if err != nil {
+			ctx = authUtils.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// persistingSource refreshes the stored token when it expires and writes the new one back
+type persistingSource struct {
+	ctx      context.Context
+	store    TokenStore
+	config   *oauth2.Config
+	userID   string
+	provider string
+
+	mu      sync.Mutex
+	token   *oauth2.Token
+	unsaved bool
+}
+
+// save writes the refreshed token back to the store, until it succeeds
+func (s *persistingSource) save() {
+	if s.unsaved && Save(s.ctx, s.store, s.userID, s.provider, s.token) == nil {
+		s.unsaved = false
+	}
+}
+
+// Token :
+// - Returns a valid token, refreshing and saving it if needed
+// - A refreshed token is returned even if it can't be saved, the provider may have rotated the
+// refresh token and the old one may not work anymore. The save is retried on the next calls
+func (s *persistingSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		s.save()
+		return s.token, nil
+	}
+	if s.token.RefreshToken == "" {
+		return nil, ErrNoRefreshToken
+	}
+	token, err := s.config.TokenSource(s.ctx, s.token).Token()
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken == s.token.AccessToken {
+		return token, nil
+	}
+	s.token = keepScope(token, s.token)
+	s.unsaved = true
+	s.save()
+	return s.token, nil
+}
+
+// keepScope :
+// - Returns refreshed with the scope of previous when the token endpoint didn't send one
+// - An omitted scope is the scope granted before (RFC 6749, 5.1)
+func keepScope(refreshed, previous *oauth2.Token) *oauth2.Token {
+	if _, ok := refreshed.Extra("scope").(string); ok {
+		return refreshed
+	}
+	scope, ok := previous.Extra("scope").(string)
+	if !ok {
+		return refreshed
+	}
+	extra := map[string]interface{}{"scope": scope}
+	if idToken, ok := refreshed.Extra("id_token").(string); ok {
+		extra["id_token"] = idToken
+	}
+	return refreshed.WithExtra(extra)
+}
+
+// TokenSource :
+// - Returns an oauth2.TokenSource for the stored token of userID and provider
+// - Expired tokens are refreshed with config and the refresh token, ErrNoRefreshToken if there is none
+// - Refreshed tokens are written back to store, so they outlive the request and the process
+// - Returns ErrTokenNotFound if no token is stored
+func TokenSource(ctx context.Context, store TokenStore, config *oauth2.Config, userID, provider string) (oauth2.TokenSource, error) {
+	token, err := store.Get(ctx, userID, provider)
+	if err != nil {
+		return nil, err
+	}
+	return &persistingSource{
+		ctx:      ctx,
+		store:    store,
+		config:   config,
+		userID:   userID,
+		provider: provider,
+		token:    token,
+	}, nil
+}
+
+// Client returns an *http.Client authenticated with TokenSource
+func Client(ctx context.Context, store TokenStore, config *oauth2.Config, userID, provider string) (*http.Client, error) {
+	source, err := TokenSource(ctx, store, config, userID, provider)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, source), nil
+}
@@ -0,0 +1,122 @@
+package authToken
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_TokenSource(t *testing.T) {
+	refreshes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		assert.Equal(t, "refresh_token", req.Form.Get("grant_type"))
+		assert.Equal(t, "refresh", req.Form.Get("refresh_token"))
+		refreshes++
+		w.Header().Set("Content-Type", "application/json")
+		// Google doesn't send a new refresh token
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":3600}`, refreshes)
+	}))
+	defer srv.Close()
+	config := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: srv.URL}}
+
+	ctx := context.Background()
+	store := NewMemoryStore(authUtils.NewKeyring([]byte("secret")))
+	expired := (&oauth2.Token{AccessToken: "access-0", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)}).
+		WithExtra(map[string]interface{}{"scope": "openid email"})
+	store.Save(ctx, "google:bob", "google", expired)
+
+	source, err := TokenSource(ctx, store, config, "google:bob", "google")
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		assert.NoError(t, err)
+		assert.Equal(t, "access-1", token.AccessToken)
+	}
+	assert.Equal(t, 1, refreshes)
+
+	// The refreshed token was written back, with the refresh token
+	stored, _ := store.Get(ctx, "google:bob", "google")
+	assert.Equal(t, "access-1", stored.AccessToken)
+	assert.Equal(t, "refresh", stored.RefreshToken)
+	// And the scope granted before, the response has none
+	assert.Equal(t, "openid email", stored.Extra("scope"))
+
+	_, err = TokenSource(ctx, store, config, "github:bob", "github")
+	assert.Equal(t, ErrTokenNotFound, err)
+}
+
+// failingStore fails to save while fail is set
+type failingStore struct {
+	TokenStore
+	fail bool
+}
+
+func (s *failingStore) Save(ctx context.Context, userID, provider string, token *oauth2.Token) error {
+	if s.fail {
+		return fmt.Errorf("store unavailable")
+	}
+	return s.TokenStore.Save(ctx, userID, provider, token)
+}
+
+func Test_TokenSource_SaveFailure(t *testing.T) {
+	refreshes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		refreshes++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"rotated","token_type":"Bearer","expires_in":3600}`, refreshes)
+	}))
+	defer srv.Close()
+	config := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: srv.URL}}
+
+	ctx := context.Background()
+	store := &failingStore{TokenStore: NewMemoryStore(authUtils.NewKeyring([]byte("secret")))}
+	store.Save(ctx, "google:bob", "google", &oauth2.Token{AccessToken: "access-0", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)})
+	source, _ := TokenSource(ctx, store, config, "google:bob", "google")
+
+	// The refreshed token is used even though it can't be saved
+	store.fail = true
+	token, err := source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "access-1", token.AccessToken)
+	stored, _ := store.Get(ctx, "google:bob", "google")
+	assert.Equal(t, "access-0", stored.AccessToken)
+
+	// The save is retried, without refreshing again
+	store.fail = false
+	token, err = source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "access-1", token.AccessToken)
+	assert.Equal(t, 1, refreshes)
+	stored, _ = store.Get(ctx, "google:bob", "google")
+	assert.Equal(t, "access-1", stored.AccessToken)
+	assert.Equal(t, "rotated", stored.RefreshToken)
+}
+
+func Test_Handler(t *testing.T) {
+	store := NewMemoryStore(authUtils.NewKeyring([]byte("secret")))
+	user := &authCommon.User{Provider: "google", Subject: "bob"}
+	called := false
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { called = true })
+
+	req := httptest.NewRequest("GET", "/callback", nil)
+	ctx := authCommon.UserToContext(req.Context(), user)
+	ctx = authCommon.TokenToContext(ctx, &oauth2.Token{AccessToken: "first", RefreshToken: "refresh"})
+	Handler(store, success, nil).ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	assert.True(t, called)
+
+	// A later login without refresh token keeps the stored one
+	ctx = authCommon.TokenToContext(ctx, &oauth2.Token{AccessToken: "second"})
+	Handler(store, success, nil).ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	token, _ := store.Get(context.Background(), "google:bob", "google")
+	assert.Equal(t, "second", token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+}
@@ -0,0 +1,100 @@
+package authToken
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/astenmies/graphql-go-auth/internal/authSQL"
+	"golang.org/x/oauth2"
+)
+
+// Placeholder is the bind parameter syntax of a SQL driver, the same as authSession.Placeholder
+type Placeholder = authSQL.Placeholder
+
+const (
+	// QuestionPlaceholder is "?", used by SQLite and MySQL
+	QuestionPlaceholder = authSQL.QuestionPlaceholder
+	// DollarPlaceholder is "$1", used by PostgreSQL
+	DollarPlaceholder = authSQL.DollarPlaceholder
+)
+
+// SQLStore :
+// - TokenStore keeping tokens in a database/sql table, encrypted with Keyring
+// - A leaked table or backup doesn't leak the refresh tokens without the keyring
+type SQLStore struct {
+	DB          *sql.DB
+	Table       string
+	Placeholder Placeholder
+	Keyring     *authUtils.Keyring
+}
+
+// NewSQLStore returns a SQLStore using table of db, see CreateTable
+func NewSQLStore(db *sql.DB, table string, keyring *authUtils.Keyring) *SQLStore {
+	return &SQLStore{DB: db, Table: table, Keyring: keyring}
+}
+
+// bind rewrites query for s.Table and s.Placeholder
+func (s *SQLStore) bind(query string) string {
+	return authSQL.Bind(s.Placeholder, s.Table, query)
+}
+
+// CreateTable creates the tokens table if it doesn't exist
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, s.bind(`CREATE TABLE IF NOT EXISTS {table} (
+		user_id VARCHAR(255) NOT NULL,
+		provider VARCHAR(64) NOT NULL,
+		data TEXT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (user_id, provider)
+	)`))
+	return err
+}
+
+// Get decrypts the token of userID for provider
+func (s *SQLStore) Get(ctx context.Context, userID, provider string) (*oauth2.Token, error) {
+	var data string
+	err := s.DB.QueryRowContext(ctx, s.bind(`SELECT data FROM {table} WHERE user_id = ? AND provider = ?`), userID, provider).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenUndecryptable, err)
+	}
+	return openToken(s.Keyring, userID, provider, sealed)
+}
+
+// Save encrypts token and replaces its row in a transaction
+func (s *SQLStore) Save(ctx context.Context, userID, provider string, token *oauth2.Token) error {
+	sealed, err := sealToken(s.Keyring, userID, provider, token)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.bind(`DELETE FROM {table} WHERE user_id = ? AND provider = ?`), userID, provider); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind(`INSERT INTO {table} (user_id, provider, data, updated_at) VALUES (?, ?, ?, ?)`),
+		userID, provider, base64.StdEncoding.EncodeToString(sealed), time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes the token of userID for provider
+func (s *SQLStore) Delete(ctx context.Context, userID, provider string) error {
+	_, err := s.DB.ExecContext(ctx, s.bind(`DELETE FROM {table} WHERE user_id = ? AND provider = ?`), userID, provider)
+	return err
+}
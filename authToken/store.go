@@ -0,0 +1,128 @@
+package authToken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"golang.org/x/oauth2"
+)
+
+// Error messages
+var (
+	ErrTokenNotFound  = authUtils.NewError("token: no token stored for this user and provider", authUtils.CodeConsentRequired)
+	ErrNoRefreshToken = authUtils.NewError("token: stored token expired and has no refresh token", authUtils.CodeConsentRequired)
+	// ErrTokenUndecryptable is wrapped by the errors of stored tokens that can't be decrypted or decoded
+	ErrTokenUndecryptable = authUtils.NewError("token: stored token can't be decrypted", authUtils.CodeInternalError)
+)
+
+// TokenStore :
+// - Keeps the OAuth2 tokens of the users, including their refresh tokens
+// - Tokens are keyed by user id and provider name
+// - Get returns ErrTokenNotFound if there is no token for them
+type TokenStore interface {
+	Get(ctx context.Context, userID, provider string) (*oauth2.Token, error)
+	Save(ctx context.Context, userID, provider string, token *oauth2.Token) error
+	Delete(ctx context.Context, userID, provider string) error
+}
+
+// storedToken is the JSON form of an oauth2.Token, which doesn't serialize its extra fields
+type storedToken struct {
+	*oauth2.Token
+	IDToken string `json:"id_token,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+}
+
+// additionalData binds a sealed token to its user and provider, so it can't be moved to another row
+func additionalData(userID, provider string) []byte {
+	return []byte(provider + "|" + userID)
+}
+
+// sealToken encrypts token with AES-GCM using the newest key of keyring
+func sealToken(keyring *authUtils.Keyring, userID, provider string, token *oauth2.Token) ([]byte, error) {
+	stored := storedToken{Token: token}
+	stored.IDToken, _ = token.Extra("id_token").(string)
+	stored.Scope, _ = token.Extra("scope").(string)
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Seal(data, additionalData(userID, provider))
+}
+
+// openToken :
+// - Decrypts a token written by sealToken
+// - Returns an error wrapping ErrTokenUndecryptable if the keyring can't decrypt it, for instance after a key was dropped
+func openToken(keyring *authUtils.Keyring, userID, provider string, sealed []byte) (*oauth2.Token, error) {
+	data, err := keyring.Open(sealed, additionalData(userID, provider))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenUndecryptable, err)
+	}
+	var stored storedToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenUndecryptable, err)
+	}
+	token := stored.Token
+	if token == nil {
+		token = &oauth2.Token{}
+	}
+	extra := map[string]interface{}{}
+	if stored.IDToken != "" {
+		extra["id_token"] = stored.IDToken
+	}
+	if stored.Scope != "" {
+		extra["scope"] = stored.Scope
+	}
+	if len(extra) > 0 {
+		token = token.WithExtra(extra)
+	}
+	return token, nil
+}
+
+// MemoryStore :
+// - In-memory TokenStore, tokens are encrypted with Keyring like in the other stores
+// - Tokens are lost when the process stops
+type MemoryStore struct {
+	Keyring *authUtils.Keyring
+
+	mu     sync.RWMutex
+	tokens map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore encrypting with keyring
+func NewMemoryStore(keyring *authUtils.Keyring) *MemoryStore {
+	return &MemoryStore{Keyring: keyring, tokens: make(map[string][]byte)}
+}
+
+// Get decrypts the token of userID for provider
+func (s *MemoryStore) Get(ctx context.Context, userID, provider string) (*oauth2.Token, error) {
+	s.mu.RLock()
+	sealed, ok := s.tokens[string(additionalData(userID, provider))]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return openToken(s.Keyring, userID, provider, sealed)
+}
+
+// Save encrypts and stores token
+func (s *MemoryStore) Save(ctx context.Context, userID, provider string, token *oauth2.Token) error {
+	sealed, err := sealToken(s.Keyring, userID, provider, token)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[string(additionalData(userID, provider))] = sealed
+	return nil
+}
+
+// Delete removes the token of userID for provider
+func (s *MemoryStore) Delete(ctx context.Context, userID, provider string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, string(additionalData(userID, provider)))
+	return nil
+}
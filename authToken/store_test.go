@@ -0,0 +1,122 @@
+package authToken
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	_ "modernc.org/sqlite"
+)
+
+// testStore checks the TokenStore contract
+func testStore(t *testing.T, store TokenStore) {
+	ctx := context.Background()
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := (&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", Expiry: expiry}).
+		WithExtra(map[string]interface{}{"id_token": "id", "scope": "openid email"})
+
+	_, err := store.Get(ctx, "google:bob", "google")
+	assert.Equal(t, ErrTokenNotFound, err)
+
+	assert.NoError(t, store.Save(ctx, "google:bob", "google", token))
+	got, err := store.Get(ctx, "google:bob", "google")
+	assert.NoError(t, err)
+	assert.Equal(t, "access", got.AccessToken)
+	assert.Equal(t, "refresh", got.RefreshToken)
+	assert.True(t, expiry.Equal(got.Expiry))
+	assert.Equal(t, "id", got.Extra("id_token"))
+	assert.Equal(t, "openid email", got.Extra("scope"))
+	_, err = store.Get(ctx, "google:bob", "github")
+	assert.Equal(t, ErrTokenNotFound, err)
+
+	assert.NoError(t, store.Save(ctx, "google:bob", "google", &oauth2.Token{AccessToken: "new"}))
+	got, _ = store.Get(ctx, "google:bob", "google")
+	assert.Equal(t, "new", got.AccessToken)
+
+	assert.NoError(t, store.Delete(ctx, "google:bob", "google"))
+	_, err = store.Get(ctx, "google:bob", "google")
+	assert.Equal(t, ErrTokenNotFound, err)
+}
+
+func Test_MemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(authUtils.NewKeyring([]byte("secret"))))
+}
+
+func Test_SQLStore(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	store := NewSQLStore(db, "tokens", authUtils.NewKeyring([]byte("secret")))
+	assert.NoError(t, store.CreateTable(context.Background()))
+	testStore(t, store)
+}
+
+func Test_SQLStore_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	db, _ := sql.Open("sqlite", ":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	keyring := authUtils.NewKeyring([]byte("old"))
+	store := NewSQLStore(db, "tokens", keyring)
+	store.CreateTable(ctx)
+	store.Save(ctx, "google:bob", "google", &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"})
+
+	// The table doesn't hold the tokens in clear
+	var data string
+	db.QueryRow(`SELECT data FROM tokens`).Scan(&data)
+	assert.False(t, strings.Contains(data, "refresh"))
+
+	// A row can't be moved to another user
+	db.Exec(`UPDATE tokens SET user_id = 'google:eve'`)
+	_, err := store.Get(ctx, "google:eve", "google")
+	assert.ErrorIs(t, err, ErrTokenUndecryptable)
+	code, _ := authUtils.ErrorCode(err)
+	assert.Equal(t, authUtils.CodeInternalError, code)
+	db.Exec(`UPDATE tokens SET user_id = 'google:bob'`)
+
+	// Rotated secrets still decrypt older rows
+	keyring.Rotate([]byte("new"), 1)
+	got, err := store.Get(ctx, "google:bob", "google")
+	assert.NoError(t, err)
+	assert.Equal(t, "refresh", got.RefreshToken)
+
+	// Until their key is dropped
+	keyring.Rotate([]byte("newer"), 0)
+	_, err = store.Get(ctx, "google:bob", "google")
+	assert.ErrorIs(t, err, ErrTokenUndecryptable)
+}
+
+func Test_MemoryStore_Undecryptable(t *testing.T) {
+	ctx := context.Background()
+	keyring := authUtils.NewKeyring([]byte("old"))
+	store := NewMemoryStore(keyring)
+	store.Save(ctx, "google:bob", "google", &oauth2.Token{AccessToken: "access"})
+
+	keyring.Rotate([]byte("new"), 0)
+	_, err := store.Get(ctx, "google:bob", "google")
+	assert.ErrorIs(t, err, ErrTokenUndecryptable)
+	code, _ := authUtils.ErrorCode(err)
+	assert.Equal(t, authUtils.CodeInternalError, code)
+}
+
+func Test_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{ErrTokenNotFound, authUtils.CodeConsentRequired},
+		{ErrNoRefreshToken, authUtils.CodeConsentRequired},
+		{ErrTokenUndecryptable, authUtils.CodeInternalError},
+	}
+	for _, tt := range tests {
+		code, _ := authUtils.ErrorCode(tt.err)
+		assert.Equal(t, tt.code, code, tt.err.Error())
+	}
+}
@@ -1,5 +1,18 @@
 package authUtils
 
+import (
+	"context"
+	"time"
+)
+
+// StateStore :
+// - Keeps track of the pending OAuth2 flows on the server side, see authCommon.StateStore
+type StateStore interface {
+	Save(ctx context.Context, state string, ttl time.Duration) error
+	Consume(ctx context.Context, state string) error
+	Expire(ctx context.Context, state string) error
+}
+
 // Config :
 // - Configures http.Cookie creation.
 type Config struct {
@@ -24,6 +37,39 @@ type Config struct {
 	Secure bool
 	// TriggerMutation is the mutation that triggers Oauth
 	TriggerMutation string
+	// TriggerMutations are additional mutations that trigger Oauth
+	TriggerMutations []string
+	// LogoutMutation is the mutation that signs the user out, see authLogout
+	LogoutMutation string
+	// ReturnToOrigins are the origins (scheme://host[:port]) the callback may redirect to
+	// after login. Relative paths of the same origin are always allowed.
+	ReturnToOrigins []string
+	// ReturnToPaths are the path prefixes the callback may redirect to, any path when empty
+	ReturnToPaths []string
+	// Keyring holds the secrets that sign the cookie value. When nil, a random
+	// key is generated for the lifetime of the process, which does not work
+	// with several server instances.
+	Keyring *Keyring
+	// Encrypt indicates whether the cookie value should also be encrypted.
+	Encrypt bool
+	// StateStore keeps the pending states of the flows started with this config.
+	// authCommon.DefaultStateStore is used when nil.
+	StateStore StateStore
+}
+
+// TriggerMutationNames :
+// - Returns TriggerMutation followed by TriggerMutations, skipping empty names
+func (c *Config) TriggerMutationNames() []string {
+	var names []string
+	if c.TriggerMutation != "" {
+		names = append(names, c.TriggerMutation)
+	}
+	for _, name := range c.TriggerMutations {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 // DefaultAuthConfig :
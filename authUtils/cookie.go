@@ -25,6 +25,15 @@ func NewCookie(config *Config, value string) *http.Cookie {
 	return cookie
 }
 
+// ExpiredCookie :
+// - Returns an empty http.Cookie with config properties and MaxAge<0
+// - Setting it deletes the cookie from the browser
+func ExpiredCookie(config *Config) *http.Cookie {
+	expired := *config
+	expired.MaxAge = -1
+	return NewCookie(&expired, "")
+}
+
 // expiresTime :
 // - Converts a maxAge time in seconds to a time.
 // - Time in the future if the maxAge is positive or the beginning of the epoch if maxAge is negative.
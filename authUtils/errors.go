@@ -0,0 +1,145 @@
+package authUtils
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// Error codes, the "code" extension of the GraphQL errors
+const (
+	CodeBadRequest      = "BAD_REQUEST"
+	CodeUnauthenticated = "UNAUTHENTICATED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeInvalidState    = "INVALID_STATE"
+	CodeConsentRequired = "CONSENT_REQUIRED"
+	CodeAccessDenied    = "ACCESS_DENIED"
+	CodeUpstreamError   = "UPSTREAM_ERROR"
+	CodeInternalError   = "INTERNAL_SERVER_ERROR"
+)
+
+// codeStatuses are the HTTP statuses of the codes
+var codeStatuses = map[string]int{
+	CodeBadRequest:      http.StatusBadRequest,
+	CodeUnauthenticated: http.StatusUnauthorized,
+	CodeForbidden:       http.StatusForbidden,
+	CodeInvalidState:    http.StatusBadRequest,
+	CodeConsentRequired: http.StatusForbidden,
+	CodeAccessDenied:    http.StatusForbidden,
+	CodeUpstreamError:   http.StatusBadGateway,
+	CodeInternalError:   http.StatusInternalServerError,
+}
+
+// Error :
+// - A sentinel error with a stable code, such as ErrInvalidCookie
+// - Compare it with errors.Is, read its code and status with ErrorCode
+// - graphql-go adds its Extensions to the GraphQL error when a resolver returns it
+// - WrapError gives a sentinel a more precise message
+type Error struct {
+	Message string
+	Code    string
+	Status  int
+	err     error
+}
+
+// NewError returns an *Error with message and code, its status is the status of code
+func NewError(message, code string) error {
+	status, ok := codeStatuses[code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	return &Error{Message: message, Code: code, Status: status}
+}
+
+// WrapError returns an *Error with message and the code and status of err, that wraps err
+func WrapError(err error, message string) error {
+	code, status := ErrorCode(err)
+	return &Error{Message: message, Code: code, Status: status, err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Extensions returns the code of the GraphQL error
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// ErrorCode :
+// - Returns the code and HTTP status of err
+// - *Error values (and errors wrapping them) have their own
+// - Errors with a "code" extension get the status of their code
+// - Token endpoint and network failures are upstream failures (502), except rejected grants (400)
+// - Other errors, like store or database failures, are internal errors (500)
+func ErrorCode(err error) (string, int) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code, e.Status
+	}
+	var extensioner interface {
+		Extensions() map[string]interface{}
+	}
+	if errors.As(err, &extensioner) {
+		if code, ok := extensioner.Extensions()["code"].(string); ok {
+			if status, ok := codeStatuses[code]; ok {
+				return code, status
+			}
+			return code, http.StatusBadRequest
+		}
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.Response != nil && retrieveErr.Response.StatusCode < 500 && retrieveErr.ErrorCode != "" {
+			return CodeBadRequest, http.StatusBadRequest
+		}
+		return CodeUpstreamError, http.StatusBadGateway
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return CodeUpstreamError, http.StatusBadGateway
+	}
+	return CodeInternalError, http.StatusInternalServerError
+}
+
+// graphQLError is a GraphQL error as in the "errors" of a response
+type graphQLError struct {
+	Message    string                 `json:"message"`
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+// GraphQLFailureHandler :
+// - Responds with the error of the ctx as a GraphQL response, that GraphQL clients can parse:
+// {"errors":[{"message":...,"extensions":{"code":...}}]}
+// - The status is the status of the error code, see ErrorCode
+// - Only the messages of coded errors (*Error or errors with a "code" extension) are sent,
+// others get the generic text of their status, so they don't leak internal details
+// - Use it as failure handler of the handlers of /graphql
+var GraphQLFailureHandler = http.HandlerFunc(graphQLFailureHandler)
+
+func graphQLFailureHandler(w http.ResponseWriter, req *http.Request) {
+	err := ErrorFromContext(req.Context())
+	code, status := ErrorCode(err)
+	message := http.StatusText(status)
+	var extensioner interface {
+		Extensions() map[string]interface{}
+	}
+	if errors.As(err, new(*Error)) || errors.As(err, &extensioner) {
+		message = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Errors []graphQLError `json:"errors"`
+	}{
+		Errors: []graphQLError{{Message: message, Extensions: map[string]interface{}{"code": code}}},
+	})
+}
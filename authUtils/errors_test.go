@@ -0,0 +1,89 @@
+package authUtils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type extensionError struct{}
+
+func (extensionError) Error() string { return "denied" }
+func (extensionError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": CodeForbidden}
+}
+
+func Test_ErrorCode(t *testing.T) {
+	tests := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{ErrInvalidCookie, CodeInvalidState, http.StatusBadRequest},
+		{fmt.Errorf("wrapped: %w", NewError("no user", CodeUnauthenticated)), CodeUnauthenticated, http.StatusUnauthorized},
+		{extensionError{}, CodeForbidden, http.StatusForbidden},
+		{WrapError(NewError("forbidden", CodeForbidden), "role required"), CodeForbidden, http.StatusForbidden},
+		{&oauth2.RetrieveError{Response: &http.Response{StatusCode: 400}, ErrorCode: "invalid_grant"}, CodeBadRequest, http.StatusBadRequest},
+		{&oauth2.RetrieveError{Response: &http.Response{StatusCode: 503}}, CodeUpstreamError, http.StatusBadGateway},
+		{&url.Error{Op: "Get", URL: "https://www.googleapis.com", Err: &timeoutError{}}, CodeUpstreamError, http.StatusBadGateway},
+		{errors.New("other"), CodeInternalError, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		code, status := ErrorCode(tt.err)
+		assert.Equal(t, tt.code, code, tt.err.Error())
+		assert.Equal(t, tt.status, status, tt.err.Error())
+	}
+}
+
+func Test_WrapError(t *testing.T) {
+	err := WrapError(ErrInvalidCookie, "cookie state: bad signature")
+	assert.True(t, errors.Is(err, ErrInvalidCookie))
+	assert.Equal(t, "cookie state: bad signature", err.Error())
+	assert.Equal(t, map[string]interface{}{"code": CodeInvalidState}, err.(*Error).Extensions())
+}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
+
+func Test_GraphQLFailureHandler(t *testing.T) {
+	serve := func(err error) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/graphql", nil)
+		GraphQLFailureHandler.ServeHTTP(w, req.WithContext(WithError(context.Background(), err)))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	w, body := serve(ErrExpiredCookie)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, map[string]interface{}{"errors": []interface{}{map[string]interface{}{
+		"message":    "cookie: expired cookie value",
+		"extensions": map[string]interface{}{"code": CodeInvalidState},
+	}}}, body)
+
+	// Upstream details are not leaked
+	w, body = serve(&url.Error{Op: "Get", URL: "https://internal.example.com", Err: &timeoutError{}})
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Bad Gateway", body["errors"].([]interface{})[0].(map[string]interface{})["message"])
+
+	// Nor internal ones
+	w, body = serve(errors.New("sql: database is locked"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, map[string]interface{}{"errors": []interface{}{map[string]interface{}{
+		"message":    "Internal Server Error",
+		"extensions": map[string]interface{}{"code": CodeInternalError},
+	}}}, body)
+}
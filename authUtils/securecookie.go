@@ -0,0 +1,261 @@
+package authUtils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error messages
+var (
+	ErrInvalidCookie = NewError("cookie: invalid or forged cookie value", CodeInvalidState)
+	ErrExpiredCookie = NewError("cookie: expired cookie value", CodeInvalidState)
+	ErrEmptyKeyring  = NewError("cookie: keyring has no key", CodeInternalError)
+)
+
+// DefaultCookieTTL is the lifetime of a signed value when Config.MaxAge is not positive
+const DefaultCookieTTL = 10 * time.Minute
+
+// clockSkew is the tolerance for issued-at timestamps set in the future
+const clockSkew = time.Minute
+
+// now is replaced in tests
+var now = time.Now
+
+// defaultKeyring :
+// - Used when Config.Keyring is nil
+// - Holds a random key that only lives as long as the process
+var defaultKeyring = NewKeyring(randomKey())
+
+func randomKey() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+// Keyring :
+// - Holds the server secrets used to sign and encrypt cookie values
+// - Values are signed with the newest key
+// - Values signed with older keys are still accepted, so secrets can be rotated
+type Keyring struct {
+	mu   sync.RWMutex
+	keys [][]byte
+}
+
+// NewKeyring :
+// - Returns a Keyring holding the given secrets
+// - Secrets are ordered from newest to oldest
+func NewKeyring(secrets ...[]byte) *Keyring {
+	k := &Keyring{}
+	for _, secret := range secrets {
+		if len(secret) > 0 {
+			k.keys = append(k.keys, secret)
+		}
+	}
+	return k
+}
+
+// Rotate :
+// - Makes secret the newest key, used for signing from now on
+// - Keeps at most keep older keys for verification (keep<0 keeps them all)
+// - An empty secret is ignored, like in NewKeyring
+func (k *Keyring) Rotate(secret []byte, keep int) {
+	if len(secret) == 0 {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append([][]byte{secret}, k.keys...)
+	if keep >= 0 && len(k.keys) > keep+1 {
+		k.keys = k.keys[:keep+1]
+	}
+}
+
+func (k *Keyring) secrets() [][]byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([][]byte(nil), k.keys...)
+}
+
+// deriveKey returns a key dedicated to one purpose (signing or encryption)
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("graphql-go-auth " + purpose))
+	return mac.Sum(nil)
+}
+
+func sign(secret []byte, name, body string) []byte {
+	mac := hmac.New(sha256.New, deriveKey(secret, "sign"))
+	mac.Write([]byte(name + "|" + body))
+	return mac.Sum(nil)
+}
+
+func newGCM(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(secret, "encrypt"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *Config) keyring() *Keyring {
+	if c.Keyring == nil {
+		return defaultKeyring
+	}
+	return c.Keyring
+}
+
+// TTL :
+// - Returns the lifetime of a signed value: MaxAge if positive, DefaultCookieTTL otherwise
+func (c *Config) TTL() time.Duration {
+	if c.MaxAge > 0 {
+		return time.Duration(c.MaxAge) * time.Second
+	}
+	return DefaultCookieTTL
+}
+
+// EncodeValue :
+// - Prefixes value with the issued-at timestamp
+// - Encrypts it with AES-GCM if config.Encrypt is set
+// - Signs it and the cookie name with HMAC-SHA256 using the newest key of config.Keyring
+func EncodeValue(config *Config, value string) (string, error) {
+	secrets := config.keyring().secrets()
+	if len(secrets) == 0 {
+		return "", ErrEmptyKeyring
+	}
+	secret := secrets[0]
+
+	payload := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(payload, uint64(now().Unix()))
+	payload = append(payload, value...)
+
+	if config.Encrypt {
+		gcm, err := newGCM(secret)
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = gcm.Seal(nonce, nonce, payload, []byte(config.Name))
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	mac := base64.RawURLEncoding.EncodeToString(sign(secret, config.Name, body))
+	return body + "." + mac, nil
+}
+
+// DecodeValue :
+// - Verifies a value written by EncodeValue against every key of config.Keyring
+// - Returns ErrInvalidCookie if the value was forged, tampered with or signed with an unknown key
+// - Returns ErrExpiredCookie if the value is older than config.MaxAge (or DefaultCookieTTL)
+func DecodeValue(config *Config, encoded string) (string, error) {
+	i := strings.LastIndex(encoded, ".")
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	body, mac := encoded[:i], encoded[i+1:]
+	gotMAC, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	var secret []byte
+	for _, s := range config.keyring().secrets() {
+		if subtle.ConstantTimeCompare(gotMAC, sign(s, config.Name, body)) == 1 {
+			secret = s
+			break
+		}
+	}
+	if secret == nil {
+		return "", ErrInvalidCookie
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if config.Encrypt {
+		gcm, err := newGCM(secret)
+		if err != nil {
+			return "", err
+		}
+		if len(payload) < gcm.NonceSize() {
+			return "", ErrInvalidCookie
+		}
+		nonce, sealed := payload[:gcm.NonceSize()], payload[gcm.NonceSize():]
+		payload, err = gcm.Open(nil, nonce, sealed, []byte(config.Name))
+		if err != nil {
+			return "", ErrInvalidCookie
+		}
+	}
+	if len(payload) < 8 {
+		return "", ErrInvalidCookie
+	}
+
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(payload[:8])), 0)
+	age := now().Sub(issuedAt)
+	if age > config.TTL() || age < -clockSkew {
+		return "", ErrExpiredCookie
+	}
+	return string(payload[8:]), nil
+}
+
+// NewSecureCookie :
+// - Returns a new http.Cookie holding value signed (and optionally encrypted) by EncodeValue
+func NewSecureCookie(config *Config, value string) (*http.Cookie, error) {
+	encoded, err := EncodeValue(config, value)
+	if err != nil {
+		return nil, err
+	}
+	return NewCookie(config, encoded), nil
+}
+
+// Seal :
+// - Encrypts plaintext with AES-GCM using the newest key, for data at rest
+// - additionalData is authenticated but not encrypted, it binds the ciphertext to its owner
+func (k *Keyring) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	secrets := k.secrets()
+	if len(secrets) == 0 {
+		return nil, ErrEmptyKeyring
+	}
+	gcm, err := newGCM(secrets[0])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open :
+// - Decrypts data written by Seal, trying every key so secrets can be rotated
+// - Returns ErrInvalidCookie if no key can decrypt it or additionalData doesn't match
+func (k *Keyring) Open(sealed, additionalData []byte) ([]byte, error) {
+	for _, secret := range k.secrets() {
+		gcm, err := newGCM(secret)
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < gcm.NonceSize() {
+			return nil, ErrInvalidCookie
+		}
+		nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+		if plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, ErrInvalidCookie
+}
@@ -0,0 +1,81 @@
+package authUtils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EncodeDecodeValue(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		config := &Config{Name: "state", MaxAge: 60, Keyring: NewKeyring([]byte("secret")), Encrypt: encrypt}
+
+		encoded, err := EncodeValue(config, "state.verifier")
+		assert.NoError(t, err)
+		if encrypt {
+			assert.NotContains(t, encoded, "state.verifier")
+		}
+
+		value, err := DecodeValue(config, encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, "state.verifier", value)
+	}
+}
+
+func Test_DecodeValue_Forged(t *testing.T) {
+	config := &Config{Name: "state", MaxAge: 60, Keyring: NewKeyring([]byte("secret"))}
+	encoded, _ := EncodeValue(config, "value")
+
+	_, err := DecodeValue(config, "value")
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	_, err = DecodeValue(config, "x"+encoded)
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	other := &Config{Name: "state", MaxAge: 60, Keyring: NewKeyring([]byte("other"))}
+	_, err = DecodeValue(other, encoded)
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	// A value can't be moved to another cookie
+	renamed := &Config{Name: "other", MaxAge: 60, Keyring: config.Keyring}
+	_, err = DecodeValue(renamed, encoded)
+	assert.Equal(t, ErrInvalidCookie, err)
+}
+
+func Test_DecodeValue_Expired(t *testing.T) {
+	defer func() { now = time.Now }()
+	config := &Config{Name: "state", MaxAge: 60, Keyring: NewKeyring([]byte("secret"))}
+
+	now = func() time.Time { return time.Unix(1000, 0) }
+	encoded, _ := EncodeValue(config, "value")
+
+	now = func() time.Time { return time.Unix(1061, 0) }
+	_, err := DecodeValue(config, encoded)
+	assert.Equal(t, ErrExpiredCookie, err)
+}
+
+func Test_Keyring_Rotate(t *testing.T) {
+	keyring := NewKeyring([]byte("old"))
+	config := &Config{Name: "state", MaxAge: 60, Keyring: keyring, Encrypt: true}
+	oldValue, _ := EncodeValue(config, "value")
+
+	keyring.Rotate([]byte("new"), 1)
+	newValue, _ := EncodeValue(config, "value")
+
+	// Signed with the newest key, older values are still valid
+	_, err := DecodeValue(&Config{Name: "state", MaxAge: 60, Keyring: NewKeyring([]byte("new")), Encrypt: true}, newValue)
+	assert.NoError(t, err)
+	value, err := DecodeValue(config, oldValue)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	// Retired keys are no longer accepted
+	keyring.Rotate([]byte("newer"), 1)
+	_, err = DecodeValue(config, oldValue)
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	// Empty secrets are ignored
+	keyring.Rotate(nil, 1)
+	assert.Equal(t, [][]byte{[]byte("newer"), []byte("new")}, keyring.secrets())
+}
@@ -0,0 +1,90 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+)
+
+// Error codes, the same as the authDirective extension codes
+const (
+	CodeUnauthenticated = authUtils.CodeUnauthenticated
+	CodeForbidden       = authUtils.CodeForbidden
+)
+
+// Error messages
+var (
+	ErrUnauthenticated = authUtils.NewError("authentication required", CodeUnauthenticated)
+	ErrForbidden       = authUtils.NewError("permission denied", CodeForbidden)
+)
+
+// Authorizer :
+// - Gives permissions to the users through their roles
+// - Resolver finds the roles of a user, Registry the permissions of the roles
+type Authorizer struct {
+	Registry *Registry
+	Resolver RoleResolver
+}
+
+// NewAuthorizer returns an Authorizer using registry and resolver
+func NewAuthorizer(registry *Registry, resolver RoleResolver) *Authorizer {
+	return &Authorizer{Registry: registry, Resolver: resolver}
+}
+
+// Middleware :
+// - Adds the Authorizer to the ctx of each request, after the session or bearer middleware
+// - The roles of the user are resolved once per request, when first needed
+func (a *Authorizer) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := requestRolesToContext(req.Context(), &requestRoles{authorizer: a})
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// rolesOrError returns the roles of the user of ctx, or ErrUnauthenticated
+func rolesOrError(ctx context.Context) ([]string, *Authorizer, error) {
+	roles, ok := ctx.Value(RolesKey).(*requestRoles)
+	if !ok {
+		return nil, nil, fmt.Errorf("authz: Context missing roles, see Authorizer.Middleware")
+	}
+	if _, err := authCommon.UserFromContext(ctx); err != nil {
+		return nil, nil, ErrUnauthenticated
+	}
+	names, err := RolesFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return names, roles.authorizer, nil
+}
+
+// Require :
+// - Returns nil if the user of ctx has permission through one of its roles
+// - Otherwise an *authUtils.Error wrapping ErrUnauthenticated or ErrForbidden, resolvers can return it as is
+func Require(ctx context.Context, permission Permission) error {
+	roles, authorizer, err := rolesOrError(ctx)
+	if err != nil {
+		return err
+	}
+	if !authorizer.Registry.Can(roles, permission) {
+		return authUtils.WrapError(ErrForbidden, fmt.Sprintf("permission %q required", permission))
+	}
+	return nil
+}
+
+// RequireRole is like Require, with a role instead of a permission
+func RequireRole(ctx context.Context, role string) error {
+	roles, _, err := rolesOrError(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range roles {
+		if name == role {
+			return nil
+		}
+	}
+	return authUtils.WrapError(ErrForbidden, fmt.Sprintf("role %q required", role))
+}
@@ -0,0 +1,66 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authUtils"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestCtx returns the ctx resolvers get for user
+func requestCtx(authorizer *Authorizer, user *authCommon.User) context.Context {
+	var ctx context.Context
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { ctx = req.Context() })
+	req := httptest.NewRequest("POST", "/graphql", nil)
+	if user != nil {
+		req = req.WithContext(authCommon.UserToContext(req.Context(), user))
+	}
+	authorizer.Middleware(next).ServeHTTP(httptest.NewRecorder(), req)
+	return ctx
+}
+
+func Test_Require(t *testing.T) {
+	registry := NewRegistry(&Role{Name: "editor", Permissions: []Permission{"posts:write"}})
+	calls := 0
+	resolver := RoleResolverFunc(func(ctx context.Context, user *authCommon.User) ([]string, error) {
+		calls++
+		return StaticRoles{"google:bob": {"editor"}}.Roles(ctx, user)
+	})
+	authorizer := NewAuthorizer(registry, resolver)
+
+	ctx := requestCtx(authorizer, &authCommon.User{Provider: "google", Subject: "bob"})
+	assert.NoError(t, Require(ctx, "posts:write"))
+	assert.NoError(t, RequireRole(ctx, "editor"))
+	err := Require(ctx, "posts:delete")
+	assert.True(t, errors.Is(err, ErrForbidden))
+	assert.Equal(t, `permission "posts:delete" required`, err.Error())
+	assert.Equal(t, map[string]interface{}{"code": CodeForbidden}, err.(*authUtils.Error).Extensions())
+	// Roles are resolved once per request
+	assert.Equal(t, 1, calls)
+
+	err = Require(requestCtx(authorizer, nil), "posts:write")
+	assert.True(t, errors.Is(err, ErrUnauthenticated))
+	err = RequireRole(requestCtx(authorizer, &authCommon.User{Provider: "github", Subject: "bob"}), "editor")
+	assert.True(t, errors.Is(err, ErrForbidden))
+}
+
+func Test_RoleResolvers(t *testing.T) {
+	ctx := context.Background()
+	alice := &authCommon.User{Provider: "github", Subject: "alice", Email: "alice@example.com", EmailVerified: true}
+
+	roles, _ := StaticRoles{"*": {"reader"}, "alice@example.com": {"admin"}}.Roles(ctx, alice)
+	assert.Equal(t, []string{"reader", "admin"}, roles)
+	alice.EmailVerified = false
+	roles, _ = StaticRoles{"*": {"reader"}, "alice@example.com": {"admin"}}.Roles(ctx, alice)
+	assert.Equal(t, []string{"reader"}, roles)
+
+	store := NewMemoryRoleStore()
+	store.SetRoles(ctx, "github:alice", []string{"editor"})
+	roles, _ = StoreRoles{Store: store}.Roles(ctx, alice)
+	assert.Equal(t, []string{"editor"}, roles)
+}
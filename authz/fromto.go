@@ -0,0 +1,52 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+)
+
+type key int
+
+const (
+	RolesKey key = iota
+)
+
+// requestRoles holds the roles of the user of a request, resolved at most once
+type requestRoles struct {
+	authorizer *Authorizer
+	once       sync.Once
+	roles      []string
+	err        error
+}
+
+func requestRolesToContext(ctx context.Context, roles *requestRoles) context.Context {
+	return context.WithValue(ctx, RolesKey, roles)
+}
+
+// RolesFromContext :
+// - Returns the roles of the authCommon.User of ctx
+// - They are resolved on the first call of the request, then read from the ctx
+func RolesFromContext(ctx context.Context) ([]string, error) {
+	roles, ok := ctx.Value(RolesKey).(*requestRoles)
+	if !ok {
+		return nil, fmt.Errorf("authz: Context missing roles, see Authorizer.Middleware")
+	}
+	user, err := authCommon.UserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles.once.Do(func() {
+		roles.roles, roles.err = roles.authorizer.Resolver.Roles(ctx, user)
+	})
+	return roles.roles, roles.err
+}
+
+// Roles :
+// - RolesFromContext with the signature of authDirective.Enforcer.Roles
+// - So @hasRole uses the roles of the Authorizer
+func Roles(ctx context.Context, user *authCommon.User) ([]string, error) {
+	return RolesFromContext(ctx)
+}
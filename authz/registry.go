@@ -0,0 +1,96 @@
+package authz
+
+import (
+	"strings"
+	"sync"
+)
+
+// Permission is an action on a resource, like "posts:write"
+// - "posts:*" grants every permission of posts, "*" grants them all
+type Permission string
+
+// Role :
+// - Names a set of permissions, like "editor"
+// - Inherits lists roles whose permissions the role also has
+type Role struct {
+	Name        string
+	Permissions []Permission
+	Inherits    []string
+}
+
+// Registry :
+// - Holds the roles of the application and their permissions
+// - Safe for concurrent use
+type Registry struct {
+	mu    sync.RWMutex
+	roles map[string]*Role
+}
+
+// NewRegistry returns a Registry holding roles
+func NewRegistry(roles ...*Role) *Registry {
+	r := &Registry{roles: make(map[string]*Role)}
+	for _, role := range roles {
+		r.Register(role)
+	}
+	return r
+}
+
+// Register adds role, replacing a role with the same name
+func (r *Registry) Register(role *Role) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[role.Name] = role
+}
+
+// Role returns the role name
+func (r *Registry) Role(name string) (*Role, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	role, ok := r.roles[name]
+	return role, ok
+}
+
+// Permissions returns the permissions of roles and of the roles they inherit
+// - Unknown roles have no permissions
+func (r *Registry) Permissions(roles []string) []Permission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var permissions []Permission
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		role, ok := r.roles[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		permissions = append(permissions, role.Permissions...)
+		for _, inherited := range role.Inherits {
+			visit(inherited)
+		}
+	}
+	for _, name := range roles {
+		visit(name)
+	}
+	return permissions
+}
+
+// grants returns true if granted covers permission, wildcards included
+func grants(granted, permission Permission) bool {
+	if granted == permission || granted == "*" {
+		return true
+	}
+	prefix := string(granted)
+	return strings.HasSuffix(prefix, ":*") && strings.HasPrefix(string(permission), prefix[:len(prefix)-1])
+}
+
+// Can returns true if one of roles has permission
+func (r *Registry) Can(roles []string, permission Permission) bool {
+	for _, granted := range r.Permissions(roles) {
+		if grants(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
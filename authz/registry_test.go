@@ -0,0 +1,26 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Registry(t *testing.T) {
+	registry := NewRegistry(
+		&Role{Name: "reader", Permissions: []Permission{"posts:read"}},
+		&Role{Name: "editor", Permissions: []Permission{"posts:write"}, Inherits: []string{"reader"}},
+		&Role{Name: "moderator", Permissions: []Permission{"comments:*"}, Inherits: []string{"editor", "moderator"}},
+		&Role{Name: "admin", Permissions: []Permission{"*"}},
+	)
+
+	assert.True(t, registry.Can([]string{"reader"}, "posts:read"))
+	assert.False(t, registry.Can([]string{"reader"}, "posts:write"))
+	assert.True(t, registry.Can([]string{"editor"}, "posts:read"))
+	assert.True(t, registry.Can([]string{"moderator"}, "comments:delete"))
+	assert.True(t, registry.Can([]string{"moderator"}, "posts:read"))
+	assert.False(t, registry.Can([]string{"moderator"}, "commentsx:delete"))
+	assert.True(t, registry.Can([]string{"admin"}, "users:delete"))
+	assert.False(t, registry.Can([]string{"unknown"}, "posts:read"))
+	assert.False(t, registry.Can(nil, "posts:read"))
+}
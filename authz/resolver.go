@@ -0,0 +1,81 @@
+package authz
+
+import (
+	"context"
+	"sync"
+
+	"github.com/astenmies/graphql-go-auth/authCommon"
+)
+
+// RoleResolver maps an authenticated user to its roles
+type RoleResolver interface {
+	Roles(ctx context.Context, user *authCommon.User) ([]string, error)
+}
+
+// RoleResolverFunc is a RoleResolver callback
+type RoleResolverFunc func(ctx context.Context, user *authCommon.User) ([]string, error)
+
+// Roles calls f
+func (f RoleResolverFunc) Roles(ctx context.Context, user *authCommon.User) ([]string, error) {
+	return f(ctx, user)
+}
+
+// StaticRoles :
+// - RoleResolver reading a static config, such as a section of the config file
+// - Keys are user ids (see authCommon.User.ID) or verified emails
+// - The roles of "*" are given to every authenticated user
+type StaticRoles map[string][]string
+
+// Roles returns the roles of the id and the verified email of user
+func (s StaticRoles) Roles(ctx context.Context, user *authCommon.User) ([]string, error) {
+	roles := append([]string{}, s["*"]...)
+	roles = append(roles, s[user.ID()]...)
+	if user.Email != "" && user.EmailVerified {
+		roles = append(roles, s[user.Email]...)
+	}
+	return roles, nil
+}
+
+// RoleStore keeps the roles assigned to user ids, in a database for instance
+type RoleStore interface {
+	GetRoles(ctx context.Context, userID string) ([]string, error)
+	SetRoles(ctx context.Context, userID string, roles []string) error
+}
+
+// StoreRoles is a RoleResolver reading the roles of the users from a RoleStore
+type StoreRoles struct {
+	Store RoleStore
+}
+
+// Roles returns the stored roles of user
+func (s StoreRoles) Roles(ctx context.Context, user *authCommon.User) ([]string, error) {
+	return s.Store.GetRoles(ctx, user.ID())
+}
+
+// MemoryRoleStore :
+// - In-memory RoleStore
+// - Roles are lost when the process stops
+type MemoryRoleStore struct {
+	mu    sync.RWMutex
+	roles map[string][]string
+}
+
+// NewMemoryRoleStore returns an empty MemoryRoleStore
+func NewMemoryRoleStore() *MemoryRoleStore {
+	return &MemoryRoleStore{roles: make(map[string][]string)}
+}
+
+// GetRoles returns the roles of userID, none if it has no roles
+func (s *MemoryRoleStore) GetRoles(ctx context.Context, userID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.roles[userID]...), nil
+}
+
+// SetRoles replaces the roles of userID
+func (s *MemoryRoleStore) SetRoles(ctx context.Context, userID string, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles[userID] = append([]string(nil), roles...)
+	return nil
+}
@@ -3,22 +3,27 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/graph-gophers/graphql-go/relay"
-	"github.com/skratchdot/open-golang/open"
 
+	facebookOAuth2 "golang.org/x/oauth2/facebook"
+	githubOAuth2 "golang.org/x/oauth2/github"
 	googleOAuth2 "golang.org/x/oauth2/google"
 
 	authCommon "github.com/astenmies/graphql-go-auth/authCommon"
+	"github.com/astenmies/graphql-go-auth/authDirective"
+	"github.com/astenmies/graphql-go-auth/authFacebook"
+	"github.com/astenmies/graphql-go-auth/authGithub"
 	"github.com/astenmies/graphql-go-auth/authGoogle"
+	"github.com/astenmies/graphql-go-auth/authLogout"
+	"github.com/astenmies/graphql-go-auth/authSession"
+	"github.com/astenmies/graphql-go-auth/authToken"
 	"github.com/astenmies/graphql-go-auth/authUtils"
 	"github.com/rs/cors"
 
-	"github.com/gorilla/sessions"
 	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
@@ -38,70 +43,111 @@ var customConfig = &authUtils.Config{
 	HTTPOnly:        true,
 	Secure:          false,          // allows cookies to be send over HTTP
 	TriggerMutation: "triggerOauth", // the mutation that triggers Oauth
+	LogoutMutation:  "logout",       // the mutation that signs the user out
 }
 
-var sessionStore = sessions.NewCookieStore([]byte(sessionSecret), nil)
-
-// Provide a cookie session after successful Google login callback
-func callbackSuccess() http.Handler {
-	fn := func(w http.ResponseWriter, req *http.Request) {
-		ctx := req.Context()
-		googleUser, err := authGoogle.UserFromContext(ctx)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Create a session
-		session, _ := sessionStore.New(req, sessionName)
-		session.Values[sessionUserKey] = googleUser.Id
-		session.Save(req, w)
-
-		// http.Redirect(w, req, "/profile", http.StatusFound)
-		// show succes page
-		msg := "<p><strong>Hello " + googleUser.GivenName + " " + googleUser.FamilyName + "</strong></p>"
-		msg = msg + "<p>You are authenticated!</p>"
-		fmt.Fprintf(w, msg)
-	}
-	return http.HandlerFunc(fn)
+var sessionCookieConfig = &authUtils.Config{
+	Name:     "graphql-go-auth-session",
+	Path:     "/",
+	HTTPOnly: true,
+	Secure:   false, // allows cookies to be send over HTTP
 }
 
-// When the whole login process is successful, this comes last
-func querySuccess(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		ctx := req.Context()
-		authURL := ctx.Value(authCommon.AuthURLKey).(string)
+// The session manager creates a session after a successful login callback
+var sessionManager = authSession.NewManager(authSession.NewMemoryStore(), sessionCookieConfig)
 
-		// Don't use this in production
-		// instead, use http.Redirect and define CORS.
-		open.Run(authURL)
-
-		// http.Redirect(w, req, authURL, http.StatusFound)
-		next.ServeHTTP(w, req.WithContext(ctx))
-	})
-}
+// The token store keeps the (encrypted) provider tokens, so resolvers can call provider APIs later on
+var tokenStore *authToken.MemoryStore
 
 func main() {
+	// Sign the state and session cookies with our secret
+	keyring := authUtils.NewKeyring([]byte(viper.GetString("gqlauth.cookie.secret")))
+	customConfig.Keyring = keyring
+	sessionCookieConfig.Keyring = keyring
+	tokenStore = authToken.NewMemoryStore(keyring)
 
 	oauth2Config := &oauth2.Config{
 		ClientID:     viper.GetString("gqlauth.oauth.google.id"),
 		ClientSecret: viper.GetString("gqlauth.oauth.google.secret"),
-		RedirectURL:  "http://localhost:8080/google/callback",
+		RedirectURL:  "http://localhost:8080/callback?provider=google",
 		Endpoint:     googleOAuth2.Endpoint,
-		Scopes:       []string{"profile", "email"},
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+	// Build the Google user from the id_token, without calling userinfo
+	googleProvider := authGoogle.NewProvider(oauth2Config)
+	googleProvider.VerifyIDToken = true
+
+	githubConfig := &oauth2.Config{
+		ClientID:     viper.GetString("gqlauth.oauth.github.id"),
+		ClientSecret: viper.GetString("gqlauth.oauth.github.secret"),
+		RedirectURL:  "http://localhost:8080/callback?provider=github",
+		Endpoint:     githubOAuth2.Endpoint,
+		Scopes:       []string{"read:user", "user:email"},
 	}
 
-	h := &relay.Handler{Schema: graphqlSchema}
-	handleSuccess := querySuccess(h)
-	handleLogin := authCommon.LoginHandler(oauth2Config, handleSuccess, nil)
-	handleState := authCommon.StateCookieHandler(customConfig, handleLogin, h)
-	http.Handle("/graphql", cors.Default().Handler(handleState))
+	facebookConfig := &oauth2.Config{
+		ClientID:     viper.GetString("gqlauth.oauth.facebook.id"),
+		ClientSecret: viper.GetString("gqlauth.oauth.facebook.secret"),
+		RedirectURL:  "http://localhost:8080/callback?provider=facebook",
+		Endpoint:     facebookOAuth2.Endpoint,
+		Scopes:       []string{"public_profile", "email"},
+	}
 
-	handleSuccess = callbackSuccess()
-	handleGoogle := authGoogle.Handler(oauth2Config, handleSuccess, nil)
-	handleCallback := authCommon.CallbackHandler(oauth2Config, handleGoogle, nil)
-	handleState = authCommon.StateCookieHandler(customConfig, handleCallback, nil)
-	http.Handle("/google/callback", handleState)
+	// Add a provider here to make it available on both routes,
+	// the trigger mutation selects it with its provider input field
+	providers := authCommon.NewRegistry(
+		googleProvider,
+		authGithub.NewProvider(githubConfig),
+		authFacebook.NewProvider(facebookConfig),
+	)
+
+	// Fields with @auth, @hasRole or @hasScope are checked before the query runs
+	enforcer, err := authDirective.NewEnforcer(Schema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The rules of the policy file are checked too, for fields without directives
+	if path := viper.GetString("gqlauth.policy"); path != "" {
+		policy, err := authDirective.LoadPolicy(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := enforcer.SetPolicy(policy); err != nil {
+			log.Fatal(err)
+		}
+	}
+	h := enforcer.Handler(&relay.Handler{Schema: graphqlSchema})
+	// GraphQL clients get the failures as GraphQL errors
+	failure := authUtils.GraphQLFailureHandler
+	// The triggerOauth resolver returns the auth URL, the page opens it in a popup
+	handleLogin := providers.LoginHandler(h, failure)
+	handleState := authCommon.LoginStateHandler(customConfig, handleLogin, h, failure)
+	// Resolvers get the user of the session with authCommon.UserFromContext
+	// and call Google APIs for that user with authGoogle.ClientFromContext
+	// The logout mutation destroys the session and revokes the provider token before its resolver runs
+	logout := authLogout.NewLogout(sessionManager, tokenStore, providers, customConfig)
+	handleState = logout.Middleware(customConfig, handleState, failure)
+	handleGraphql := sessionManager.Middleware(googleProvider.ClientMiddleware(tokenStore, handleState))
+	http.Handle("/graphql", cors.Default().Handler(handleGraphql))
+
+	// The callback page posts the login result to the page that opened the popup, then closes itself.
+	// The session is already created, the message holds the result of the query too
+	popup, err := authCommon.NewPopup(viper.GetStringSlice("gqlauth.popup.origins")...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	callbackSuccess := popup.Success(&authCommon.CallbackQuery{
+		Schema: graphqlSchema,
+		Query:  "{ me }",
+		Exec:   enforcer.Exec,
+	}, nil)
+	handleSuccess := authToken.Handler(tokenStore, sessionManager.Handler(callbackSuccess, popup.Failure()), popup.Failure())
+	handleCallback := providers.CallbackHandler(handleSuccess, popup.Failure())
+	handleState = authCommon.CallbackStateHandler(customConfig, handleCallback, popup.Failure())
+	http.Handle("/callback", handleState)
+
+	// Or sign out without GraphQL, with a POST request
+	http.Handle("/logout", logout.Handler(nil, nil))
 
 	// Write a GraphiQL page to /
 	http.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -126,16 +172,18 @@ var users = []User{
 //// GraphQL Schema ////
 
 // Schema describes the data that we ask for
-var Schema = `
+var Schema = authDirective.Directives + `
     schema {
 		query: Query
         mutation: Mutation
 	}
 	type Query {
 		user(input: UserInput!): String!
+		me: String @auth
 	}
 	type Mutation {
 		triggerOauth(input: UserLoginInput!): String!
+		logout: Boolean!
 	}
 	type User {
 		id: ID!
@@ -147,6 +195,7 @@ var Schema = `
 	}
     input UserLoginInput {
 		username: String!
+		provider: String
 	}
     `
 
@@ -169,11 +218,6 @@ func init() {
 }
 
 //// Resolvers ////
-var (
-	sessionName    = "gqlauth"
-	sessionSecret  = viper.GetString("gqlauth.cookie.secret")
-	sessionUserKey = "googleID"
-)
 
 // User :
 // - Resolves User query
@@ -183,6 +227,17 @@ func (r *Resolver) User(ctx context.Context, args *struct {
 	return "Username"
 }
 
+// Me :
+// - Resolves me query with the user of the session
+// - @auth makes sure there is one
+func (r *Resolver) Me(ctx context.Context) *string {
+	user, err := authCommon.UserFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	return &user.Name
+}
+
 // triggerOauth :
 // - Resolves triggerOauth mutation
 func (r *Resolver) TriggerOauth(ctx context.Context, args *struct {
@@ -191,6 +246,17 @@ func (r *Resolver) TriggerOauth(ctx context.Context, args *struct {
 	return ctx.Value(authCommon.AuthURLKey).(string)
 }
 
+// Logout :
+// - Resolves logout mutation, the user is already signed out
+// - Returns false if the provider token couldn't be revoked
+func (r *Resolver) Logout(ctx context.Context) bool {
+	result, err := authLogout.ResultFromContext(ctx)
+	if err != nil {
+		return false
+	}
+	return result.RevocationErr == nil
+}
+
 //// Graphql Types ////
 
 // Resolver common struct
@@ -213,6 +279,8 @@ type UserInput struct {
 // but you may also enable to define the username later on (on a profile page for instance)
 type UserLoginInput struct {
 	Username string
+	// Provider selects the provider of the registry, "google", "github" or "facebook"
+	Provider *string
 }
 
 //// GraphiQL ////
@@ -238,12 +306,27 @@ var page = []byte(`
                         return response.text();
                     }).then(function (responseBody) {
                         try {
-                            return JSON.parse(responseBody);
+                            var result = JSON.parse(responseBody);
                         } catch (error) {
                             return responseBody;
                         }
+                        // Sign in within a popup, the callback page posts the result back
+                        if (result.data && result.data.triggerOauth) {
+                            window.open(result.data.triggerOauth, "graphql-go-auth", "width=500,height=600");
+                        }
+                        return result;
                     });
                 }
+                window.addEventListener("message", function (event) {
+                    if (event.origin !== window.location.origin || !event.data || event.data.source !== "graphql-go-auth") {
+                        return;
+                    }
+                    if (event.data.type === "login") {
+                        console.log("Signed in", event.data.user, event.data.response);
+                    } else {
+                        console.log("Sign-in failed", event.data.code);
+                    }
+                });
                 ReactDOM.render(
                     React.createElement(GraphiQL, {fetcher: graphQLFetcher}),
                     document.getElementById("graphiql")
@@ -21,5 +21,11 @@ if [ ! -f _config/global.json ]; then
     echo ">>> Please open _config/global.json and update it with your config."
 fi
 
+if [ ! -f _config/policy.json ]; then
+    echo "Creating _config/policy.json"
+    cp _config/policy.example.json _config/policy.json
+    echo "Done."
+fi
+
 echo "Starting app with realize..."
 realize start --run main.go
\ No newline at end of file
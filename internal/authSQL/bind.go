@@ -0,0 +1,38 @@
+// Package authSQL holds what the SQL stores of authSession and authToken share
+package authSQL
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Placeholder is the bind parameter syntax of a SQL driver
+type Placeholder int
+
+const (
+	// QuestionPlaceholder is "?", used by SQLite and MySQL
+	QuestionPlaceholder Placeholder = iota
+	// DollarPlaceholder is "$1", used by PostgreSQL
+	DollarPlaceholder
+)
+
+// Bind :
+// - Replaces {table} in query with table
+// - Rewrites the "?" of query for placeholder
+func Bind(placeholder Placeholder, table, query string) string {
+	query = strings.Replace(query, "{table}", table, -1)
+	if placeholder != DollarPlaceholder {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}